@@ -0,0 +1,54 @@
+// Package azimuth checks headings against the range of a rotator. It is
+// used by the pages as well as by the REST API of the hub.
+package azimuth
+
+import (
+	"fmt"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+// Check returns an error if the rotator with the configuration cfg
+// can not be turned to az. Rotators which turn more than 360° (overlap)
+// accept headings up to their maximum azimuth, rotators with a
+// range smaller than 360° may wrap around north (min > max).
+// If the range is unknown, 0...359 is assumed.
+func Check(cfg rotator.Config, az int) error {
+	min, max := cfg.AzimuthMin, cfg.AzimuthMax
+	if min == 0 && max == 0 {
+		max = 359
+	}
+
+	if min > max {
+		// the range includes north, e.g. 270...90
+		if az >= 0 && az < 360 && (az >= min || az <= max) {
+			return nil
+		}
+		return fmt.Errorf("%d° out of range (%d...%d°)", az, min, max)
+	}
+
+	if az < min || az > max {
+		return fmt.Errorf("%d° out of range (%d...%d°)", az, min, max)
+	}
+	return nil
+}
+
+// ForBearing returns the azimuth the rotator with the configuration cfg
+// has to be turned to in order to point at bearing (0...359°). Rotators
+// whose range starts beyond north (e.g. 180...540°) reach the bearings
+// below their minimum one turn later. If a bearing can be reached twice
+// (overlap), the lower azimuth is returned. The result still needs to
+// be checked with Check.
+func ForBearing(cfg rotator.Config, bearing int) int {
+	bearing = (bearing%360 + 360) % 360
+	min, max := cfg.AzimuthMin, cfg.AzimuthMax
+	if min > max {
+		return bearing // the range wraps around north
+	}
+	for az := bearing; az <= max; az += 360 {
+		if az >= min {
+			return az
+		}
+	}
+	return bearing
+}
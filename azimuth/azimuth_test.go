@@ -0,0 +1,86 @@
+package azimuth
+
+import (
+	"testing"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+func TestCheck(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		min, max int
+		az       int
+		err      string // "": in range
+	}{
+		{"full circle", 0, 359, 180, ""},
+		{"full circle, north", 0, 359, 0, ""},
+		{"full circle, max", 0, 359, 359, ""},
+		{"full circle, 360", 0, 359, 360, "360° out of range (0...359°)"},
+		{"negative", 0, 359, -1, "-1° out of range (0...359°)"},
+		{"unknown range", 0, 0, 0, ""},
+		{"unknown range, max", 0, 0, 359, ""},
+		{"unknown range, beyond", 0, 0, 360, "360° out of range (0...359°)"},
+		{"overlap", 0, 450, 450, ""},
+		{"overlap, beyond", 0, 450, 451, "451° out of range (0...450°)"},
+		{"starts south", 180, 540, 179, "179° out of range (180...540°)"},
+		{"starts south, overlap", 180, 540, 540, ""},
+		{"limited range", 90, 270, 89, "89° out of range (90...270°)"},
+		{"limited range, min", 90, 270, 90, ""},
+		{"wraps around north, min", 270, 90, 270, ""},
+		{"wraps around north, north", 270, 90, 0, ""},
+		{"wraps around north, max", 270, 90, 90, ""},
+		{"wraps around north, south", 270, 90, 180, "180° out of range (270...90°)"},
+		{"wraps around north, 360", 270, 90, 360, "360° out of range (270...90°)"},
+		{"wraps around north, negative", 270, 90, -10, "-10° out of range (270...90°)"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Check(rotator.Config{AzimuthMin: tc.min, AzimuthMax: tc.max}, tc.az)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.err {
+				t.Fatalf("got error %v, want '%s'", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestForBearing(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		min, max int
+		bearing  int
+		want     int
+	}{
+		{"unknown range", 0, 0, 270, 270},
+		{"full circle", 0, 359, 0, 0},
+		{"overlap, lower azimuth", 0, 450, 30, 30},
+		{"overlap beyond", 0, 450, 120, 120},
+		{"starts south, east", 180, 540, 90, 450},
+		{"starts south, north", 180, 540, 0, 360},
+		{"starts south, west", 180, 540, 270, 270},
+		{"starts south, at min", 180, 540, 180, 180},
+		{"wraps around north", 270, 90, 45, 45},
+		{"wraps around north, outside", 270, 90, 180, 180},
+		{"out of range", 90, 270, 10, 10},
+		{"bearing of 360", 180, 540, 360, 360},
+		{"negative bearing", 0, 359, -90, 270},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := rotator.Config{AzimuthMin: tc.min, AzimuthMax: tc.max}
+			if az := ForBearing(cfg, tc.bearing); az != tc.want {
+				t.Fatalf("ForBearing(%d...%d°, %d°) = %d°, want %d°", tc.min, tc.max, tc.bearing, az, tc.want)
+			}
+		})
+	}
+}
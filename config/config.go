@@ -0,0 +1,643 @@
+// Package config contains the station specific layout of touchctl
+// (bands, stackmatches, terminals) which is loaded from a YAML file
+// at startup.
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dh1tw/touchctl/cty"
+	"github.com/dh1tw/touchctl/geo"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the root object of the configuration file.
+type Config struct {
+	Bands      []Band      `yaml:"bands"`
+	Stacks     []Stack     `yaml:"stacks"`
+	Bandswitch *Bandswitch `yaml:"bandswitch"`
+	RigFollow  *RigFollow  `yaml:"rig_follow"`
+	Jog        *Jog        `yaml:"jog"`
+	QTH        *Position   `yaml:"qth"`
+	Locators   []Locator   `yaml:"locators"`
+	Presets    []Preset    `yaml:"presets"`
+	Prefixes   []string    `yaml:"prefixes"` // DXCC prefixes added to the presets
+	Cty        string      `yaml:"cty"`      // country file; default: built-in table
+}
+
+// CountryTable returns the table of DXCC prefixes which is used to
+// look up the bearings of the prefixes.
+func (c *Config) CountryTable() (*cty.Table, error) {
+	if c.Cty == "" {
+		return cty.Default()
+	}
+	return cty.Load(c.Cty)
+}
+
+// Band is a button on the band page. The frequency range (kHz) is used
+// to follow the radio; if omitted, the IARU band limits are used.
+type Band struct {
+	Name      string `yaml:"name"`
+	ShortName string `yaml:"short_name"` // max 5 chars
+	Button    int    `yaml:"button"`
+	MinFreq   int    `yaml:"min_freq"` // kHz
+	MaxFreq   int    `yaml:"max_freq"` // kHz
+}
+
+// RigFollow configures the automatic selection of the stack page
+// matching the band of the radio.
+type RigFollow struct {
+	Enabled bool   `yaml:"enabled"` // state at startup
+	Button  int    `yaml:"button"`  // toggle button on the band page
+	Text    string `yaml:"text"`    // max 5 chars
+}
+
+// Jog configures the CW / CCW keys of the rotator pages. While a key is
+// held, the rotator is turned by Step degrees every Repeat interval.
+type Jog struct {
+	Step   int           `yaml:"step"`   // degrees; default 5
+	Repeat time.Duration `yaml:"repeat"` // e.g. 300ms (default)
+}
+
+// Position is a location given either as Maidenhead locator or as
+// latitude / longitude (degrees, north and east positive).
+type Position struct {
+	Locator string   `yaml:"locator"`
+	Lat     *float64 `yaml:"lat"`
+	Lon     *float64 `yaml:"lon"`
+}
+
+// Point returns the coordinates of the position.
+func (p Position) Point() (geo.Point, error) {
+	if p.Locator != "" {
+		if p.Lat != nil || p.Lon != nil {
+			return geo.Point{}, fmt.Errorf("either locator or lat / lon must be provided")
+		}
+		return geo.ParseLocator(p.Locator)
+	}
+	if p.Lat == nil || p.Lon == nil {
+		return geo.Point{}, fmt.Errorf("locator or lat / lon must be provided")
+	}
+	pt := geo.Point{Lat: *p.Lat, Lon: *p.Lon}
+	return pt, pt.Valid()
+}
+
+// Locator is a frequently used location which can be picked on the
+// locator page to point the rotator at it.
+type Locator struct {
+	Name     string `yaml:"name"` // max 5 chars
+	Position `yaml:",inline"`
+}
+
+// Stack describes the stackmatch page of a band.
+type Stack struct {
+	Band         string        `yaml:"band"`
+	Switch       string        `yaml:"switch"`
+	Rotators     []Rotator     `yaml:"rotators"`
+	Terminals    []Terminal    `yaml:"terminals"`
+	Combinations []Combination `yaml:"combinations"`
+	MinActive    *int          `yaml:"min_active"` // default: 1
+	MaxActive    int           `yaml:"max_active"` // 0: no limit
+}
+
+// defaultMinActive prevents that all antennas are switched off the stack.
+const defaultMinActive = 1
+
+// MinActiveTerminals returns the minimum number of terminals which must
+// remain active on the stack.
+func (s Stack) MinActiveTerminals() int {
+	if s.MinActive == nil {
+		return defaultMinActive
+	}
+	return *s.MinActive
+}
+
+// Rotator is a rotator shown on a stack page. Its heading is shown on
+// Button, its short name on LabelButton. Pressing Button opens the
+// rotator page.
+type Rotator struct {
+	Name        string   `yaml:"name"`         // rotator name in the registry
+	ShortName   string   `yaml:"short_name"`   // max 5 chars
+	Button      int      `yaml:"button"`       // heading
+	LabelButton int      `yaml:"label_button"` // short name
+	Display     string   `yaml:"display"`      // "heading" (default) or "compass"
+	Presets     []Preset `yaml:"presets"`      // replace the station presets
+}
+
+// Preset is a heading which can be selected on the preset page.
+type Preset struct {
+	Name    string `yaml:"name"`    // max 5 chars
+	Azimuth int    `yaml:"azimuth"` // degrees
+}
+
+// Rotator displays.
+const (
+	DisplayHeading = "heading"
+	DisplayCompass = "compass"
+)
+
+// Terminal is a stackmatch terminal (antenna) shown on a stack page.
+type Terminal struct {
+	Name      string `yaml:"name"`       // terminal name on the switch
+	ShortName string `yaml:"short_name"` // max 5 chars
+	Index     int    `yaml:"index"`
+	Button    int    `yaml:"button"`
+}
+
+// Combination is a named pattern of terminals (e.g. BIP, BOP, ALL) which
+// is set with a single button press. The listed terminals are switched on,
+// all others off.
+type Combination struct {
+	Name      string   `yaml:"name"` // max 5 chars
+	Button    int      `yaml:"button"`
+	Terminals []string `yaml:"terminals"`
+}
+
+// Bandswitch describes the page of a two radio bandswitch (ports A and B).
+// It is opened from the band page.
+type Bandswitch struct {
+	Switch    string     `yaml:"switch"`
+	ShortName string     `yaml:"short_name"` // label on the band page
+	Button    int        `yaml:"button"`     // button on the band page
+	Terminals []Terminal `yaml:"terminals"`
+}
+
+// Error is a validation error which points to the offending key in
+// the configuration file.
+type Error struct {
+	File string
+	Line int
+	Key  string
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d: %s: %s", e.File, e.Line, e.Key, e.Msg)
+}
+
+// Load reads, parses and validates the configuration file at path.
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(path, data)
+}
+
+// Parse parses and validates a configuration. The name is only used
+// for error messages.
+func Parse(name string, data []byte) (*Config, error) {
+	cfg := &Config{}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	// decode a second time into a node tree, so that validation errors
+	// can be reported with the line of the offending key
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	v := &validator{file: name, root: &root}
+	if err := v.validate(cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Band returns the band with the given name.
+func (c *Config) Band(name string) (Band, bool) {
+	for _, b := range c.Bands {
+		if b.Name == name {
+			return b, true
+		}
+	}
+	return Band{}, false
+}
+
+type validator struct {
+	file string
+	root *yaml.Node
+}
+
+// errorf returns an *Error for the key at path. Path elements are either
+// mapping keys (string) or sequence indexes (int).
+func (v *validator) errorf(path []interface{}, format string, a ...interface{}) error {
+	return &Error{
+		File: v.file,
+		Line: v.line(path),
+		Key:  keyName(path),
+		Msg:  fmt.Sprintf(format, a...),
+	}
+}
+
+// line returns the line of the deepest node which can be found along path.
+func (v *validator) line(path []interface{}) int {
+	n := v.root
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	line := n.Line
+
+	for _, p := range path {
+		var next *yaml.Node
+		switch p := p.(type) {
+		case string:
+			if n.Kind != yaml.MappingNode {
+				return line
+			}
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if n.Content[i].Value == p {
+					line = n.Content[i].Line
+					next = n.Content[i+1]
+					break
+				}
+			}
+		case int:
+			if n.Kind != yaml.SequenceNode || p >= len(n.Content) {
+				return line
+			}
+			next = n.Content[p]
+			line = next.Line
+		}
+		if next == nil {
+			return line
+		}
+		n = next
+	}
+
+	return line
+}
+
+func keyName(path []interface{}) string {
+	var sb strings.Builder
+	for _, p := range path {
+		switch p := p.(type) {
+		case string:
+			if sb.Len() > 0 {
+				sb.WriteString(".")
+			}
+			sb.WriteString(p)
+		case int:
+			sb.WriteString("[" + strconv.Itoa(p) + "]")
+		}
+	}
+	return sb.String()
+}
+
+// numButtons is the amount of keys on the Stream Deck
+const numButtons = 15
+
+// maxShortName is the maximum amount of characters which fit on a label
+const maxShortName = 5
+
+// maxLocators is the number of keys on the locator list page (all
+// keys except back).
+const maxLocators = numButtons - 1
+
+// bandswitchReservedBtns are the keys on the bandswitch page which can
+// not be used for terminals (port selection, back).
+var bandswitchReservedBtns = map[int]bool{4: true, 14: true}
+
+// stackReservedBtns are the keys on a stack page which can not be
+// used for rotators or stackmatch terminals (back).
+var stackReservedBtns = map[int]bool{14: true}
+
+func (v *validator) validate(c *Config) error {
+
+	if len(c.Bands) == 0 {
+		return v.errorf([]interface{}{"bands"}, "at least one band must be configured")
+	}
+
+	bandNames := map[string]bool{}
+	bandBtns := map[int]bool{}
+
+	for i, b := range c.Bands {
+		p := []interface{}{"bands", i}
+		if b.Name == "" {
+			return v.errorf(p, "name must not be empty")
+		}
+		if bandNames[b.Name] {
+			return v.errorf(append(p, "name"), "band '%s' defined twice", b.Name)
+		}
+		bandNames[b.Name] = true
+		if err := v.shortName(append(p, "short_name"), b.ShortName); err != nil {
+			return err
+		}
+		if b.Button < 0 || b.Button >= numButtons {
+			return v.errorf(append(p, "button"), "%d out of range (0..%d)", b.Button, numButtons-1)
+		}
+		if bandBtns[b.Button] {
+			return v.errorf(append(p, "button"), "button %d used twice", b.Button)
+		}
+		bandBtns[b.Button] = true
+		if b.MinFreq < 0 {
+			return v.errorf(append(p, "min_freq"), "%d kHz must not be negative", b.MinFreq)
+		}
+		if b.MaxFreq < b.MinFreq {
+			return v.errorf(append(p, "max_freq"), "invalid frequency range %d..%d kHz", b.MinFreq, b.MaxFreq)
+		}
+	}
+
+	stacks := map[string]bool{}
+
+	for i, s := range c.Stacks {
+		p := []interface{}{"stacks", i}
+		if !bandNames[s.Band] {
+			return v.errorf(append(p, "band"), "unknown band '%s'", s.Band)
+		}
+		if stacks[s.Band] {
+			return v.errorf(append(p, "band"), "stack for band '%s' defined twice", s.Band)
+		}
+		stacks[s.Band] = true
+		if s.Switch == "" {
+			return v.errorf(append(p, "switch"), "switch name must not be empty")
+		}
+
+		if s.MinActiveTerminals() < 0 {
+			return v.errorf(append(p, "min_active"), "must not be negative")
+		}
+		if s.MaxActive < 0 {
+			return v.errorf(append(p, "max_active"), "must not be negative")
+		}
+		if s.MaxActive > 0 && s.MaxActive < s.MinActiveTerminals() {
+			return v.errorf(append(p, "max_active"), "%d is smaller than min_active (%d)", s.MaxActive, s.MinActiveTerminals())
+		}
+
+		btns := map[int]bool{}
+		if err := v.validateRotators(append(p, "rotators"), s.Rotators, btns); err != nil {
+			return err
+		}
+
+		names := map[string]bool{}
+		for j, t := range s.Terminals {
+			tp := append(p, "terminals", j)
+			if t.Name == "" {
+				return v.errorf(append(tp, "name"), "terminal name must not be empty")
+			}
+			if names[t.Name] {
+				return v.errorf(append(tp, "name"), "terminal '%s' defined twice", t.Name)
+			}
+			names[t.Name] = true
+			if err := v.shortName(append(tp, "short_name"), t.ShortName); err != nil {
+				return err
+			}
+			if t.Button < 0 || t.Button >= numButtons {
+				return v.errorf(append(tp, "button"), "%d out of range (0..%d)", t.Button, numButtons-1)
+			}
+			if stackReservedBtns[t.Button] {
+				return v.errorf(append(tp, "button"), "button %d is reserved on stack pages", t.Button)
+			}
+			if btns[t.Button] {
+				return v.errorf(append(tp, "button"), "button %d used twice", t.Button)
+			}
+			btns[t.Button] = true
+		}
+
+		for j, c := range s.Combinations {
+			cp := append(p, "combinations", j)
+			if err := v.shortName(append(cp, "name"), c.Name); err != nil {
+				return err
+			}
+			if c.Button < 0 || c.Button >= numButtons {
+				return v.errorf(append(cp, "button"), "%d out of range (0..%d)", c.Button, numButtons-1)
+			}
+			if stackReservedBtns[c.Button] {
+				return v.errorf(append(cp, "button"), "button %d is reserved on stack pages", c.Button)
+			}
+			if btns[c.Button] {
+				return v.errorf(append(cp, "button"), "button %d used twice", c.Button)
+			}
+			btns[c.Button] = true
+			for k, t := range c.Terminals {
+				if !names[t] {
+					return v.errorf(append(cp, "terminals", k), "unknown terminal '%s'", t)
+				}
+			}
+			if len(c.Terminals) < s.MinActiveTerminals() ||
+				(s.MaxActive > 0 && len(c.Terminals) > s.MaxActive) {
+				return v.errorf(append(cp, "terminals"), "combination '%s' violates min_active / max_active", c.Name)
+			}
+		}
+	}
+
+	if c.Bandswitch != nil {
+		if err := v.validateBandswitch(c.Bandswitch, bandNames, bandBtns); err != nil {
+			return err
+		}
+		bandBtns[c.Bandswitch.Button] = true
+	}
+
+	if rf := c.RigFollow; rf != nil {
+		p := []interface{}{"rig_follow"}
+		if rf.Button < 0 || rf.Button >= numButtons {
+			return v.errorf(append(p, "button"), "%d out of range (0..%d)", rf.Button, numButtons-1)
+		}
+		if bandBtns[rf.Button] {
+			return v.errorf(append(p, "button"), "button %d is already used on the band page", rf.Button)
+		}
+		if err := v.shortName(append(p, "text"), rf.Text); err != nil {
+			return err
+		}
+	}
+
+	if j := c.Jog; j != nil {
+		p := []interface{}{"jog"}
+		if j.Step < 0 || j.Step >= 180 {
+			return v.errorf(append(p, "step"), "%d out of range (0..179)", j.Step)
+		}
+		if j.Repeat < 0 {
+			return v.errorf(append(p, "repeat"), "must not be negative")
+		}
+	}
+
+	if err := v.validatePresets([]interface{}{"presets"}, c.Presets); err != nil {
+		return err
+	}
+
+	if c.QTH != nil {
+		if _, err := c.QTH.Point(); err != nil {
+			return v.errorf([]interface{}{"qth"}, "%v", err)
+		}
+	}
+
+	if err := v.validatePrefixes(c); err != nil {
+		return err
+	}
+
+	if len(c.Locators) > 0 && c.QTH == nil {
+		return v.errorf([]interface{}{"locators"}, "qth must be configured")
+	}
+	if len(c.Locators) > maxLocators {
+		return v.errorf([]interface{}{"locators"}, "at most %d locators can be configured", maxLocators)
+	}
+	for i, l := range c.Locators {
+		p := []interface{}{"locators", i}
+		if err := v.shortName(append(p, "name"), l.Name); err != nil {
+			return err
+		}
+		if _, err := l.Point(); err != nil {
+			return v.errorf(p, "%v", err)
+		}
+	}
+
+	return nil
+}
+
+func (v *validator) validateBandswitch(bs *Bandswitch, bandNames map[string]bool, bandBtns map[int]bool) error {
+	p := []interface{}{"bandswitch"}
+	if bs.Switch == "" {
+		return v.errorf(append(p, "switch"), "switch name must not be empty")
+	}
+	if err := v.shortName(append(p, "short_name"), bs.ShortName); err != nil {
+		return err
+	}
+	if bandNames[bs.ShortName] {
+		return v.errorf(append(p, "short_name"), "'%s' is already used as band name", bs.ShortName)
+	}
+	if bs.Button < 0 || bs.Button >= numButtons {
+		return v.errorf(append(p, "button"), "%d out of range (0..%d)", bs.Button, numButtons-1)
+	}
+	if bandBtns[bs.Button] {
+		return v.errorf(append(p, "button"), "button %d is already used on the band page", bs.Button)
+	}
+
+	names := map[string]bool{}
+	btns := map[int]bool{}
+	for i, t := range bs.Terminals {
+		tp := append(p, "terminals", i)
+		if t.Name == "" {
+			return v.errorf(append(tp, "name"), "terminal name must not be empty")
+		}
+		if names[t.Name] {
+			return v.errorf(append(tp, "name"), "terminal '%s' defined twice", t.Name)
+		}
+		names[t.Name] = true
+		if err := v.shortName(append(tp, "short_name"), t.ShortName); err != nil {
+			return err
+		}
+		if t.Button < 0 || t.Button >= numButtons {
+			return v.errorf(append(tp, "button"), "%d out of range (0..%d)", t.Button, numButtons-1)
+		}
+		if bandswitchReservedBtns[t.Button] {
+			return v.errorf(append(tp, "button"), "button %d is reserved on the bandswitch page", t.Button)
+		}
+		if btns[t.Button] {
+			return v.errorf(append(tp, "button"), "button %d used twice", t.Button)
+		}
+		btns[t.Button] = true
+	}
+
+	return nil
+}
+
+// validateRotators checks the rotators of a stack page and marks their
+// buttons as used in btns.
+func (v *validator) validateRotators(p []interface{}, rotators []Rotator, btns map[int]bool) error {
+	names := map[string]bool{}
+	for i, r := range rotators {
+		rp := append(p, i)
+		if r.Name == "" {
+			return v.errorf(append(rp, "name"), "rotator name must not be empty")
+		}
+		if names[r.Name] {
+			return v.errorf(append(rp, "name"), "rotator '%s' defined twice", r.Name)
+		}
+		names[r.Name] = true
+		if err := v.shortName(append(rp, "short_name"), r.ShortName); err != nil {
+			return err
+		}
+		if err := v.validatePresets(append(rp, "presets"), r.Presets); err != nil {
+			return err
+		}
+		switch r.Display {
+		case "", DisplayHeading, DisplayCompass:
+		default:
+			return v.errorf(append(rp, "display"), "unknown display '%s' (%s, %s)", r.Display, DisplayHeading, DisplayCompass)
+		}
+		for _, key := range []string{"button", "label_button"} {
+			btn := r.Button
+			if key == "label_button" {
+				btn = r.LabelButton
+			}
+			if btn < 0 || btn >= numButtons {
+				return v.errorf(append(rp, key), "%d out of range (0..%d)", btn, numButtons-1)
+			}
+			if stackReservedBtns[btn] {
+				return v.errorf(append(rp, key), "button %d is reserved on stack pages", btn)
+			}
+			if btns[btn] {
+				return v.errorf(append(rp, key), "button %d used twice", btn)
+			}
+			btns[btn] = true
+		}
+	}
+	return nil
+}
+
+// maxAzimuth is the maximum heading of a preset; rotators with overlap
+// turn up to 450°.
+const maxAzimuth = 450
+
+func (v *validator) validatePresets(p []interface{}, presets []Preset) error {
+	for i, ps := range presets {
+		pp := append(p, i)
+		if err := v.shortName(append(pp, "name"), ps.Name); err != nil {
+			return err
+		}
+		if ps.Azimuth < 0 || ps.Azimuth > maxAzimuth {
+			return v.errorf(append(pp, "azimuth"), "%d out of range (0..%d)", ps.Azimuth, maxAzimuth)
+		}
+	}
+	return nil
+}
+
+// validatePrefixes checks that the prefixes can be found in the
+// country table.
+func (v *validator) validatePrefixes(c *Config) error {
+	if len(c.Prefixes) == 0 {
+		return nil
+	}
+
+	p := []interface{}{"prefixes"}
+	if c.QTH == nil {
+		return v.errorf(p, "qth must be configured")
+	}
+
+	t, err := c.CountryTable()
+	if err != nil {
+		return v.errorf([]interface{}{"cty"}, "%v", err)
+	}
+
+	for i, prefix := range c.Prefixes {
+		if err := v.shortName(append(p, i), prefix); err != nil {
+			return err
+		}
+		if _, ok := t.Lookup(prefix); !ok {
+			return v.errorf(append(p, i), "unknown prefix '%s'", prefix)
+		}
+	}
+
+	return nil
+}
+
+func (v *validator) shortName(path []interface{}, name string) error {
+	if name == "" {
+		return v.errorf(path, "must not be empty")
+	}
+	if len(name) > maxShortName {
+		return v.errorf(path, "'%s' is longer than %d characters", name, maxShortName)
+	}
+	return nil
+}
@@ -0,0 +1,415 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{
+			name: "unknown key",
+			yaml: `bands:
+  - {name: 20m, short_name: 20m, button: 5}
+band_page: 1
+`,
+			want: "test.yaml: yaml: unmarshal errors:\n  line 3: field band_page not found in type config.Config",
+		},
+		{
+			name: "no bands",
+			yaml: `bands: []
+`,
+			want: "test.yaml:1: bands: at least one band must be configured",
+		},
+		{
+			name: "band without name",
+			yaml: `bands:
+  - name: 20m
+    short_name: 20m
+    button: 5
+  - short_name: 40m
+    button: 6
+`,
+			want: "test.yaml:5: bands[1]: name must not be empty",
+		},
+		{
+			name: "band button used twice",
+			yaml: `bands:
+  - name: 20m
+    short_name: 20m
+    button: 5
+  - name: 40m
+    short_name: 40m
+    button: 5
+`,
+			want: "test.yaml:7: bands[1].button: button 5 used twice",
+		},
+		{
+			name: "short name too long",
+			yaml: `bands:
+  - name: 160m
+    short_name: " 160m "
+    button: 5
+`,
+			want: "test.yaml:3: bands[0].short_name: ' 160m ' is longer than 5 characters",
+		},
+		{
+			name: "invalid frequency range",
+			yaml: `bands:
+  - {name: 20m, short_name: 20m, button: 5, min_freq: 14350, max_freq: 14000}
+`,
+			want: "test.yaml:2: bands[0].max_freq: invalid frequency range 14350..14000 kHz",
+		},
+		{
+			name: "stack of an unknown band",
+			yaml: `bands:
+  - {name: 20m, short_name: 20m, button: 5}
+stacks:
+  - band: 40m
+    switch: Stackmatch 40m
+`,
+			want: "test.yaml:4: stacks[0].band: unknown band '40m'",
+		},
+		{
+			name: "max_active smaller than min_active",
+			yaml: `bands:
+  - {name: 20m, short_name: 20m, button: 5}
+stacks:
+  - band: 20m
+    switch: Stackmatch 20m
+    min_active: 2
+    max_active: 1
+`,
+			want: "test.yaml:7: stacks[0].max_active: 1 is smaller than min_active (2)",
+		},
+		{
+			name: "rotator on the back key",
+			yaml: `bands:
+  - {name: 20m, short_name: 20m, button: 5}
+stacks:
+  - band: 20m
+    switch: Stackmatch 20m
+    rotators:
+      - {name: Tower1, short_name: TWR1, button: 8, label_button: 3}
+      - name: Tower2
+        short_name: TWR2
+        button: 14
+        label_button: 2
+`,
+			want: "test.yaml:10: stacks[0].rotators[1].button: button 14 is reserved on stack pages",
+		},
+		{
+			name: "unknown rotator display",
+			yaml: `bands:
+  - {name: 20m, short_name: 20m, button: 5}
+stacks:
+  - band: 20m
+    switch: Stackmatch 20m
+    rotators:
+      - {name: Tower1, short_name: TWR1, button: 8, label_button: 3, display: dial}
+`,
+			want: "test.yaml:7: stacks[0].rotators[0].display: unknown display 'dial' (heading, compass)",
+		},
+		{
+			name: "rotator preset out of range",
+			yaml: `bands:
+  - {name: 20m, short_name: 20m, button: 5}
+stacks:
+  - band: 20m
+    switch: Stackmatch 20m
+    rotators:
+      - name: Tower1
+        short_name: TWR1
+        button: 8
+        label_button: 3
+        presets:
+          - {name: JA, azimuth: 40}
+          - name: VK
+            azimuth: 451
+`,
+			want: "test.yaml:14: stacks[0].rotators[0].presets[1].azimuth: 451 out of range (0..450)",
+		},
+		{
+			name: "terminal and rotator on the same key",
+			yaml: `bands:
+  - {name: 20m, short_name: 20m, button: 5}
+stacks:
+  - band: 20m
+    switch: Stackmatch 20m
+    rotators:
+      - {name: Tower1, short_name: TWR1, button: 8, label_button: 3}
+    terminals:
+      - {name: OB11-TWR1, short_name: OB11, index: 0, button: 3}
+`,
+			want: "test.yaml:9: stacks[0].terminals[0].button: button 3 used twice",
+		},
+		{
+			name: "combination of an unknown terminal",
+			yaml: `bands:
+  - {name: 20m, short_name: 20m, button: 5}
+stacks:
+  - band: 20m
+    switch: Stackmatch 20m
+    terminals:
+      - {name: OB11-TWR1, short_name: OB11, index: 0, button: 13}
+    combinations:
+      - name: ALL
+        button: 9
+        terminals:
+          - OB11-TWR1
+          - OB11-TWR2
+`,
+			want: "test.yaml:13: stacks[0].combinations[0].terminals[1]: unknown terminal 'OB11-TWR2'",
+		},
+		{
+			name: "combination violates max_active",
+			yaml: `bands:
+  - {name: 20m, short_name: 20m, button: 5}
+stacks:
+  - band: 20m
+    switch: Stackmatch 20m
+    max_active: 1
+    terminals:
+      - {name: OB11-TWR1, short_name: OB11, index: 0, button: 13}
+      - {name: OB11-TWR2, short_name: OB11, index: 1, button: 12}
+    combinations:
+      - name: ALL
+        button: 9
+        terminals: [OB11-TWR1, OB11-TWR2]
+`,
+			want: "test.yaml:13: stacks[0].combinations[0].terminals: combination 'ALL' violates min_active / max_active",
+		},
+		{
+			name: "bandswitch named like a band",
+			yaml: `bands:
+  - {name: 20m, short_name: 20m, button: 5}
+bandswitch:
+  switch: Bandswitch
+  short_name: 20m
+  button: 14
+`,
+			want: "test.yaml:5: bandswitch.short_name: '20m' is already used as band name",
+		},
+		{
+			name: "bandswitch terminal on the port key",
+			yaml: `bands:
+  - {name: 20m, short_name: 20m, button: 5}
+bandswitch:
+  switch: Bandswitch
+  short_name: " BS "
+  button: 14
+  terminals:
+    - {name: 20m, short_name: 20m, button: 4}
+`,
+			want: "test.yaml:8: bandswitch.terminals[0].button: button 4 is reserved on the bandswitch page",
+		},
+		{
+			name: "rig follow on a band key",
+			yaml: `bands:
+  - {name: 20m, short_name: 20m, button: 5}
+bandswitch:
+  switch: Bandswitch
+  short_name: " BS "
+  button: 14
+rig_follow:
+  button: 14
+  text: AUTO
+`,
+			want: "test.yaml:8: rig_follow.button: button 14 is already used on the band page",
+		},
+		{
+			name: "jog step out of range",
+			yaml: `bands:
+  - {name: 20m, short_name: 20m, button: 5}
+jog:
+  step: 180
+`,
+			want: "test.yaml:4: jog.step: 180 out of range (0..179)",
+		},
+		{
+			name: "preset without name",
+			yaml: `bands:
+  - {name: 20m, short_name: 20m, button: 5}
+presets:
+  - {name: N, azimuth: 0}
+  - {azimuth: 90}
+`,
+			want: "test.yaml:5: presets[1].name: must not be empty",
+		},
+		{
+			name: "invalid qth",
+			yaml: `bands:
+  - {name: 20m, short_name: 20m, button: 5}
+qth:
+  locator: JO62
+  lat: 52.5
+`,
+			want: "test.yaml:3: qth: either locator or lat / lon must be provided",
+		},
+		{
+			name: "locators without qth",
+			yaml: `bands:
+  - {name: 20m, short_name: 20m, button: 5}
+locators:
+  - {name: VK6, locator: OF78}
+`,
+			want: "test.yaml:3: locators: qth must be configured",
+		},
+		{
+			name: "invalid locator",
+			yaml: `bands:
+  - {name: 20m, short_name: 20m, button: 5}
+qth:
+  locator: JO62qm
+locators:
+  - {name: VK6, locator: OF78}
+  - {name: ZL, lat: -41.3}
+`,
+			want: "test.yaml:7: locators[1]: locator or lat / lon must be provided",
+		},
+		{
+			name: "unknown prefix",
+			yaml: `bands:
+  - {name: 20m, short_name: 20m, button: 5}
+qth:
+  locator: JO62qm
+prefixes:
+  - JA
+  - QQ
+`,
+			want: "test.yaml:7: prefixes[1]: unknown prefix 'QQ'",
+		},
+		{
+			name: "missing country file",
+			yaml: `bands:
+  - {name: 20m, short_name: 20m, button: 5}
+qth:
+  locator: JO62qm
+prefixes: [JA]
+cty: testdata/missing.dat
+`,
+			want: "test.yaml:6: cty: open testdata/missing.dat: no such file or directory",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := Parse("test.yaml", []byte(tc.yaml))
+			if err == nil {
+				t.Fatalf("expected an error, got %+v", cfg)
+			}
+			if err.Error() != tc.want {
+				t.Fatalf("got error\n%q\nwant\n%q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+
+	t.Run("bands only", func(t *testing.T) {
+		cfg, err := Parse("test.yaml", []byte(`bands:
+  - {name: 20m, short_name: 20m, button: 5}
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, ok := cfg.Band("20m"); !ok || b.Button != 5 {
+			t.Fatalf("got band %+v (%v), want 20m on button 5", b, ok)
+		}
+		if cfg.Bandswitch != nil || cfg.RigFollow != nil || cfg.Jog != nil || cfg.QTH != nil {
+			t.Fatalf("unexpected optional sections: %+v", cfg)
+		}
+	})
+
+	t.Run("all sections", func(t *testing.T) {
+		cfg, err := Parse("test.yaml", []byte(`bands:
+  - {name: 20m, short_name: 20m, button: 5, min_freq: 14000, max_freq: 14350}
+  - {name: 40m, short_name: 40m, button: 12}
+stacks:
+  - band: 20m
+    switch: Stackmatch 20m
+    min_active: 0
+    max_active: 2
+    rotators:
+      - {name: Tower1, short_name: TWR1, button: 8, label_button: 3, display: compass}
+      - name: Tower2
+        short_name: TWR2
+        button: 7
+        label_button: 2
+        presets:
+          - {name: JA, azimuth: 40}
+          - {name: W6, azimuth: 330}
+    terminals:
+      - {name: OB11-TWR1, short_name: OB11, index: 0, button: 13}
+      - {name: OB11-TWR2, short_name: OB11, index: 1, button: 12}
+    combinations:
+      - {name: ALL, button: 9, terminals: [OB11-TWR1, OB11-TWR2]}
+bandswitch:
+  switch: Bandswitch
+  short_name: " BS "
+  button: 14
+  terminals:
+    - {name: 20m, short_name: 20m, button: 5}
+rig_follow:
+  enabled: true
+  button: 0
+  text: AUTO
+jog:
+  step: 10
+  repeat: 200ms
+qth:
+  locator: JO62qm
+locators:
+  - {name: VK6, locator: OF78}
+  - {name: ZL, lat: -41.3, lon: 174.8}
+presets:
+  - {name: N, azimuth: 0}
+  - {name: LP, azimuth: 450}
+prefixes: [JA, VK]
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s := cfg.Stacks[0]
+		if s.MinActiveTerminals() != 0 || s.MaxActive != 2 {
+			t.Fatalf("got min_active %d / max_active %d, want 0 / 2", s.MinActiveTerminals(), s.MaxActive)
+		}
+		if len(s.Rotators) != 2 || len(s.Rotators[1].Presets) != 2 || s.Rotators[0].Display != DisplayCompass {
+			t.Fatalf("unexpected rotators: %+v", s.Rotators)
+		}
+		if cfg.Jog.Step != 10 || cfg.Jog.Repeat != time.Millisecond*200 {
+			t.Fatalf("got jog %+v, want 10° / 200ms", cfg.Jog)
+		}
+		if len(cfg.Locators) != 2 || len(cfg.Prefixes) != 2 {
+			t.Fatalf("got %d locators and %d prefixes, want 2 and 2", len(cfg.Locators), len(cfg.Prefixes))
+		}
+	})
+
+	t.Run("min_active defaults to 1", func(t *testing.T) {
+		cfg, err := Parse("test.yaml", []byte(`bands:
+  - {name: 20m, short_name: 20m, button: 5}
+stacks:
+  - band: 20m
+    switch: Stackmatch 20m
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := cfg.Stacks[0].MinActiveTerminals(); n != 1 {
+			t.Fatalf("got min_active %d, want 1", n)
+		}
+	})
+
+	t.Run("example configuration", func(t *testing.T) {
+		if _, err := Load("../touchctl.yaml"); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,266 @@
+// Package cty reads country files in the cty.dat format (see
+// www.country-files.com) and looks up the DXCC entity of a prefix or
+// a callsign.
+//
+// A small table with the most common entities is built in. It can be
+// replaced by the complete file from www.country-files.com.
+package cty
+
+import (
+	"bufio"
+	_ "embed" // built-in country file
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dh1tw/touchctl/geo"
+)
+
+//go:embed cty.dat
+var builtin string
+
+// Entity is a DXCC entity (or another entity of the country file, e.g.
+// a WAE country).
+type Entity struct {
+	Name      string
+	CQZone    int
+	ITUZone   int
+	Continent string
+	Point     geo.Point // north and east positive
+	UTCOffset float64   // hours
+	Prefix    string    // primary prefix
+}
+
+// Table maps the prefixes and callsigns of a country file to their
+// entities.
+type Table struct {
+	prefixes map[string]Entity
+	calls    map[string]Entity // exact callsigns (=CALL)
+}
+
+// Default returns the built-in table.
+func Default() (*Table, error) {
+	return Parse(strings.NewReader(builtin))
+}
+
+// Load reads the country file at path.
+func Load(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads a country file. Each entity consists of a header line
+//
+//	Name: CQ zone: ITU zone: continent: lat: lon: UTC offset: prefix:
+//
+// followed by the comma separated list of its prefixes, which ends with
+// a semicolon and may span several lines. The longitude and the UTC
+// offset are given positive to the west. A prefix may override the
+// values of its entity: (CQ zone), [ITU zone], <lat/lon>, {continent}
+// and ~UTC offset~. Callsigns starting with '=' only match exactly.
+func Parse(r io.Reader) (*Table, error) {
+	t := &Table{
+		prefixes: make(map[string]Entity),
+		calls:    make(map[string]Entity),
+	}
+
+	var e *Entity
+	aliases := ""
+	lineNr := 0
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineNr++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if e == nil {
+			ent, err := parseHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNr, err)
+			}
+			e = &ent
+			continue
+		}
+
+		// prefixes never contain ':', it is the next header
+		if strings.Contains(line, ":") {
+			return nil, fmt.Errorf("line %d: prefixes of %s not terminated by ';'", lineNr, e.Name)
+		}
+
+		aliases += line
+		if !strings.HasSuffix(aliases, ";") {
+			continue
+		}
+
+		if err := t.add(*e, strings.TrimSuffix(aliases, ";")); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNr, err)
+		}
+		e = nil
+		aliases = ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if e != nil {
+		return nil, fmt.Errorf("line %d: prefixes of %s not terminated by ';'", lineNr, e.Name)
+	}
+
+	return t, nil
+}
+
+// parseHeader parses the header line of an entity.
+func parseHeader(line string) (Entity, error) {
+	fields := strings.Split(line, ":")
+	if len(fields) != 9 || strings.TrimSpace(fields[8]) != "" {
+		return Entity{}, fmt.Errorf("invalid entity '%s'", line)
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+
+	e := Entity{
+		Name:      fields[0],
+		Continent: fields[3],
+		Prefix:    strings.TrimPrefix(fields[7], "*"), // *: not on the DXCC list
+	}
+
+	var err error
+	if e.CQZone, err = strconv.Atoi(fields[1]); err != nil {
+		return Entity{}, fmt.Errorf("%s: invalid CQ zone '%s'", e.Name, fields[1])
+	}
+	if e.ITUZone, err = strconv.Atoi(fields[2]); err != nil {
+		return Entity{}, fmt.Errorf("%s: invalid ITU zone '%s'", e.Name, fields[2])
+	}
+	if e.Point, err = parsePoint(fields[4], fields[5]); err != nil {
+		return Entity{}, fmt.Errorf("%s: %v", e.Name, err)
+	}
+	if e.UTCOffset, err = parseOffset(fields[6]); err != nil {
+		return Entity{}, fmt.Errorf("%s: invalid UTC offset '%s'", e.Name, fields[6])
+	}
+
+	return e, nil
+}
+
+// parseOffset parses a UTC offset which is given positive to the west.
+func parseOffset(s string) (float64, error) {
+	offset, err := strconv.ParseFloat(s, 64)
+	return 0 - offset, err
+}
+
+// parsePoint parses a position with the longitude positive to the west.
+func parsePoint(lat, lon string) (geo.Point, error) {
+	la, err := strconv.ParseFloat(lat, 64)
+	if err != nil {
+		return geo.Point{}, fmt.Errorf("invalid latitude '%s'", lat)
+	}
+	lo, err := strconv.ParseFloat(lon, 64)
+	if err != nil {
+		return geo.Point{}, fmt.Errorf("invalid longitude '%s'", lon)
+	}
+	p := geo.Point{Lat: la, Lon: -lo}
+	return p, p.Valid()
+}
+
+// add adds the comma separated prefixes of the entity e.
+func (t *Table) add(e Entity, aliases string) error {
+	if e.Prefix != "" {
+		if _, ok := t.prefixes[e.Prefix]; !ok {
+			t.prefixes[e.Prefix] = e
+		}
+	}
+
+	for _, a := range strings.Split(aliases, ",") {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		exact := strings.HasPrefix(a, "=")
+		prefix, pe, err := parseAlias(e, strings.TrimPrefix(a, "="))
+		if err != nil {
+			return fmt.Errorf("%s: %v", e.Name, err)
+		}
+		if exact {
+			t.calls[prefix] = pe
+			continue
+		}
+		t.prefixes[prefix] = pe
+	}
+
+	return nil
+}
+
+// parseAlias splits a prefix from its overrides and returns the entity
+// with the overrides applied.
+func parseAlias(e Entity, alias string) (string, Entity, error) {
+	i := strings.IndexAny(alias, "([<{~")
+	if i < 0 {
+		return alias, e, nil
+	}
+	prefix, overrides := alias[:i], alias[i:]
+	if prefix == "" {
+		return "", e, fmt.Errorf("invalid prefix '%s'", alias)
+	}
+
+	closing := map[byte]byte{'(': ')', '[': ']', '<': '>', '{': '}', '~': '~'}
+
+	for len(overrides) > 0 {
+		open := overrides[0]
+		end := strings.IndexByte(overrides[1:], closing[open])
+		if end < 0 {
+			return "", e, fmt.Errorf("invalid prefix '%s'", alias)
+		}
+		value := overrides[1 : end+1]
+		overrides = overrides[end+2:]
+
+		var err error
+		switch open {
+		case '(':
+			e.CQZone, err = strconv.Atoi(value)
+		case '[':
+			e.ITUZone, err = strconv.Atoi(value)
+		case '<':
+			pos := strings.Split(value, "/")
+			if len(pos) != 2 {
+				return "", e, fmt.Errorf("invalid prefix '%s'", alias)
+			}
+			e.Point, err = parsePoint(pos[0], pos[1])
+		case '{':
+			e.Continent = value
+		case '~':
+			e.UTCOffset, err = parseOffset(value)
+		}
+		if err != nil {
+			return "", e, fmt.Errorf("invalid prefix '%s'", alias)
+		}
+	}
+
+	return prefix, e, nil
+}
+
+// Lookup returns the entity of a prefix (e.g. "W6") or a callsign. The
+// longest matching prefix of the table is used.
+func (t *Table) Lookup(call string) (Entity, bool) {
+	call = strings.ToUpper(strings.TrimSpace(call))
+
+	if e, ok := t.calls[call]; ok {
+		return e, true
+	}
+
+	for i := len(call); i > 0; i-- {
+		if e, ok := t.prefixes[call[:i]]; ok {
+			return e, true
+		}
+	}
+
+	return Entity{}, false
+}
@@ -0,0 +1,131 @@
+package cty
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9
+}
+
+func TestLoad(t *testing.T) {
+
+	tbl, err := Load("testdata/sample.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		call      string
+		entity    string
+		prefix    string
+		cq, itu   int
+		continent string
+		lat, lon  float64
+		offset    float64
+	}{
+		{"header", "DL", "Fed. Rep. of Germany", "DL", 14, 28, "EU", 51, 10, 1},
+		{"alias", "DA0A", "Fed. Rep. of Germany", "DL", 14, 28, "EU", 51, 10, 1},
+		{"alias on continuation line", "Y9A", "Fed. Rep. of Germany", "DL", 14, 28, "EU", 51, 10, 1},
+		{"west longitude", "N", "United States", "K", 5, 8, "NA", 37.53, -91.67, -5},
+		{"east longitude", "JA1XYZ", "Japan", "JA", 25, 45, "AS", 36.4, 138.38, 9},
+		{"lower case", "ja1xyz", "Japan", "JA", 25, 45, "AS", 36.4, 138.38, 9},
+		{"longest prefix", "W6ABC", "United States", "K", 3, 6, "NA", 36.5, -119.5, -8},
+		{"shorter prefix", "W1ABC", "United States", "K", 5, 8, "NA", 37.53, -91.67, -5},
+		{"overrides without offset", "K6ABC", "United States", "K", 3, 6, "NA", 36.5, -119.5, -5},
+		{"prefix of another entity", "WL7ABC", "Alaska", "KL", 1, 1, "NA", 61.4, -148.87, -9},
+		{"exact call", "DL0XX/LH", "Fed. Rep. of Germany", "DL", 14, 28, "EU", 51, 10, 1},
+		{"exact call of another entity", "W1AW/KL", "Alaska", "KL", 1, 1, "NA", 61.4, -148.87, -9},
+		{"exact call with overrides", "W1AW/KH6", "United States", "K", 31, 61, "OC", 21.3, -157.8, -10},
+		{"exact call only matches exactly", "W1AW/KH6X", "United States", "K", 5, 8, "NA", 37.53, -91.67, -5},
+		{"not on the DXCC list", "CY0A", "Sable Island", "CY0", 5, 9, "NA", 43.93, -59.9, -4},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e, ok := tbl.Lookup(tc.call)
+			if !ok {
+				t.Fatalf("%s not found", tc.call)
+			}
+			if e.Name != tc.entity || e.Prefix != tc.prefix {
+				t.Fatalf("got %s (%s), want %s (%s)", e.Name, e.Prefix, tc.entity, tc.prefix)
+			}
+			if e.CQZone != tc.cq || e.ITUZone != tc.itu || e.Continent != tc.continent {
+				t.Fatalf("got CQ %d, ITU %d, %s, want CQ %d, ITU %d, %s",
+					e.CQZone, e.ITUZone, e.Continent, tc.cq, tc.itu, tc.continent)
+			}
+			if !near(e.Point.Lat, tc.lat) || !near(e.Point.Lon, tc.lon) {
+				t.Fatalf("got %v/%v, want %v/%v", e.Point.Lat, e.Point.Lon, tc.lat, tc.lon)
+			}
+			if !near(e.UTCOffset, tc.offset) {
+				t.Fatalf("got UTC offset %v, want %v", e.UTCOffset, tc.offset)
+			}
+		})
+	}
+
+	for _, call := range []string{"", "Q1ABC", "=DL0XX/LH"} {
+		if e, ok := tbl.Lookup(call); ok {
+			t.Fatalf("%q: got %s, want no entity", call, e.Name)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"missing semicolon",
+			"Japan: 25: 45: AS: 36.40: -138.38: -9.0: JA:\n    JA,JE\n",
+			"line 2: prefixes of Japan not terminated by ';'"},
+		{"missing semicolon before next entity",
+			"Japan: 25: 45: AS: 36.40: -138.38: -9.0: JA:\n    JA,JE\nAlaska: 01: 01: NA: 61.40: 148.87: 9.0: KL:\n    KL;\n",
+			"line 3: prefixes of Japan not terminated by ';'"},
+		{"invalid header",
+			"Japan: 25: 45: AS: 36.40: -138.38: JA:\n    JA;\n",
+			"line 1: invalid entity"},
+		{"invalid zone",
+			"Japan: 25: x: AS: 36.40: -138.38: -9.0: JA:\n    JA;\n",
+			"line 1: Japan: invalid ITU zone 'x'"},
+		{"invalid latitude",
+			"Japan: 25: 45: AS: 96.40: -138.38: -9.0: JA:\n    JA;\n",
+			"line 1: Japan:"},
+		{"unterminated override",
+			"Japan: 25: 45: AS: 36.40: -138.38: -9.0: JA:\n    JA(25;\n",
+			"line 2: Japan: invalid prefix 'JA(25'"},
+		{"invalid override",
+			"Japan: 25: 45: AS: 36.40: -138.38: -9.0: JA:\n    JA<36.4>;\n",
+			"line 2: Japan: invalid prefix 'JA<36.4>'"},
+		{"override without prefix",
+			"Japan: 25: 45: AS: 36.40: -138.38: -9.0: JA:\n    (25);\n",
+			"line 2: Japan: invalid prefix '(25)'"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tc.data))
+			if err == nil {
+				t.Fatal("no error")
+			}
+			if !strings.HasPrefix(err.Error(), tc.err) {
+				t.Fatalf("got error '%v', want '%s...'", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestDefault(t *testing.T) {
+
+	tbl, err := Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := tbl.Lookup("W6"); !ok || e.CQZone != 3 {
+		t.Fatalf("got %+v, want the W6 override of the built-in table", e)
+	}
+}
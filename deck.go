@@ -0,0 +1,92 @@
+package main
+
+import (
+	"sync"
+
+	esd "github.com/dh1tw/streamdeck"
+)
+
+// deck keeps track of the page tree and the page which is currently
+// shown on the Stream Deck.
+type deck struct {
+	sync.Mutex
+	sd          *esd.StreamDeck
+	layout      *layout
+	currentPage esd.Page
+}
+
+func newDeck(sd *esd.StreamDeck, l *layout) *deck {
+	d := &deck{
+		sd:          sd,
+		layout:      l,
+		currentPage: l.root,
+	}
+
+	l.register()
+	d.currentPage.SetActive(true)
+	d.currentPage.Draw()
+
+	return d
+}
+
+// btnEvent is the callback for the Stream Deck button events.
+func (d *deck) btnEvent(keyIndex int, state esd.BtnState) {
+	d.Lock()
+	defer d.Unlock()
+	newPage := d.currentPage.Set(keyIndex, state)
+	if newPage != nil {
+		d.show(newPage)
+	}
+}
+
+func (d *deck) show(p esd.Page) {
+	d.currentPage.SetActive(false)
+	d.sd.ClearAllBtns()
+	d.currentPage = p
+	d.currentPage.SetActive(true)
+	d.currentPage.Draw()
+}
+
+// redraw renders the current page again, e.g. after the TX lock
+// has changed.
+func (d *deck) redraw() {
+	d.Lock()
+	defer d.Unlock()
+	d.currentPage.Draw()
+}
+
+// showPage shows the stack or bandswitch page with the given name,
+// unless the page (or one of its sub pages) is already shown.
+func (d *deck) showPage(name string) {
+	d.Lock()
+	defer d.Unlock()
+
+	p, ok := d.layout.page(name)
+	if !ok {
+		return
+	}
+	if current, ok := d.layout.pageOf(d.currentPage); ok && current == name {
+		return
+	}
+	d.show(p)
+}
+
+// replaceLayout swaps the page tree. If the page which is currently shown
+// (or the stack / bandswitch page it belongs to) also exists in the new layout, the
+// new version of that page is shown, otherwise we fall back to the root page.
+func (d *deck) replaceLayout(l *layout) {
+	d.Lock()
+	defer d.Unlock()
+
+	next := l.root
+	if name, ok := d.layout.pageOf(d.currentPage); ok {
+		if p, ok := l.page(name); ok {
+			next = p
+		}
+	}
+
+	d.layout.unregister()
+	l.register()
+	d.layout = l
+	d.show(next)
+}
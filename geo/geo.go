@@ -0,0 +1,103 @@
+// Package geo contains the great circle calculations which are needed
+// to point an antenna at a station: the position of a Maidenhead
+// locator, and the bearing and distance between two points.
+package geo
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// earthRadius is the mean radius of the earth in km.
+const earthRadius = 6371.0
+
+// Point is a position on the earth in degrees. Latitudes north and
+// longitudes east are positive.
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// Valid returns an error if the latitude or the longitude is out
+// of range.
+func (p Point) Valid() error {
+	if p.Lat < -90 || p.Lat > 90 {
+		return fmt.Errorf("latitude %.4f out of range (-90...90)", p.Lat)
+	}
+	if p.Lon < -180 || p.Lon > 180 {
+		return fmt.Errorf("longitude %.4f out of range (-180...180)", p.Lon)
+	}
+	return nil
+}
+
+func (p Point) String() string {
+	return fmt.Sprintf("%.4f, %.4f", p.Lat, p.Lon)
+}
+
+// ParseLocator returns the center of a Maidenhead locator with 2 (field),
+// 4 (square), 6 (subsquare) or 8 (extended square) characters, e.g.
+// "JO62" or "JO62qm". The locator is not case sensitive.
+func ParseLocator(locator string) (Point, error) {
+	loc := strings.ToUpper(strings.TrimSpace(locator))
+	if len(loc) == 0 || len(loc) > 8 || len(loc)%2 != 0 {
+		return Point{}, fmt.Errorf("invalid locator '%s'", locator)
+	}
+
+	// size of the field, square, subsquare and extended square (degrees)
+	lonSize := []float64{20, 2, 2.0 / 24, 2.0 / 240}
+	latSize := []float64{10, 1, 1.0 / 24, 1.0 / 240}
+	// range of the characters of each pair
+	first := []byte{'A', '0', 'A', '0'}
+	last := []byte{'R', '9', 'X', '9'}
+
+	lon, lat := -180.0, -90.0
+	pairs := len(loc) / 2
+	for i := 0; i < pairs; i++ {
+		c1, c2 := loc[2*i], loc[2*i+1]
+		if c1 < first[i] || c1 > last[i] || c2 < first[i] || c2 > last[i] {
+			return Point{}, fmt.Errorf("invalid locator '%s'", locator)
+		}
+		lon += float64(c1-first[i]) * lonSize[i]
+		lat += float64(c2-first[i]) * latSize[i]
+	}
+
+	// center of the smallest square
+	lon += lonSize[pairs-1] / 2
+	lat += latSize[pairs-1] / 2
+
+	return Point{Lat: lat, Lon: lon}, nil
+}
+
+// Bearing returns the initial great circle bearing (short path) from
+// one point to another in degrees (0...359.99, clockwise from north).
+func Bearing(from, to Point) float64 {
+	lat1, lat2 := rad(from.Lat), rad(to.Lat)
+	dLon := rad(to.Lon - from.Lon)
+
+	y := math.Sin(dLon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLon)
+
+	return math.Mod(deg(math.Atan2(y, x))+360, 360)
+}
+
+// Distance returns the great circle distance (short path) between two
+// points in km.
+func Distance(from, to Point) float64 {
+	lat1, lat2 := rad(from.Lat), rad(to.Lat)
+	dLat := lat2 - lat1
+	dLon := rad(to.Lon - from.Lon)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+func rad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func deg(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
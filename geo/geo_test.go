@@ -0,0 +1,123 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestParseLocator(t *testing.T) {
+
+	tests := []struct {
+		locator string
+		want    Point
+		ok      bool
+	}{
+		// field, square, subsquare and extended square
+		{"JO", Point{Lat: 55, Lon: 10}, true},
+		{"JO62", Point{Lat: 52.5, Lon: 13}, true},
+		{"JO62QM", Point{Lat: 52.520833, Lon: 13.375}, true},
+		{"JO62QM55", Point{Lat: 52.522917, Lon: 13.379167}, true},
+		{"FN20", Point{Lat: 40.5, Lon: -75}, true},
+		{"AA00aa00", Point{Lat: -89.997917, Lon: -179.995833}, true},
+		{"RR99xx99", Point{Lat: 89.997917, Lon: 179.995833}, true},
+
+		// lower case and surrounding spaces
+		{"jo62qm", Point{Lat: 52.520833, Lon: 13.375}, true},
+		{" Jo62Qm ", Point{Lat: 52.520833, Lon: 13.375}, true},
+
+		// invalid characters
+		{"SO62", Point{}, false},
+		{"JZ62", Point{}, false},
+		{"JOA2", Point{}, false},
+		{"JO62YM", Point{}, false},
+		{"JO62QMA5", Point{}, false},
+		{"JO6-", Point{}, false},
+
+		// odd or invalid length
+		{"", Point{}, false},
+		{"J", Point{}, false},
+		{"JO6", Point{}, false},
+		{"JO62Q", Point{}, false},
+		{"JO62QM5", Point{}, false},
+		{"JO62QM55AA", Point{}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.locator, func(t *testing.T) {
+			p, err := ParseLocator(tc.locator)
+			if !tc.ok {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", p)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !near(p.Lat, tc.want.Lat, 1e-6) || !near(p.Lon, tc.want.Lon, 1e-6) {
+				t.Fatalf("got %v, want %v", p, tc.want)
+			}
+		})
+	}
+}
+
+func TestBearingDistance(t *testing.T) {
+
+	jo62 := Point{Lat: 52.5, Lon: 13}
+	fn20 := Point{Lat: 40.5, Lon: -75}
+	halfCircumference := math.Pi * earthRadius
+
+	tests := []struct {
+		name     string
+		from, to Point
+		bearing  float64
+		distance float64
+	}{
+		{"JO62 to FN20", jo62, fn20, 296.22, 6438.2},
+		{"FN20 to JO62", fn20, jo62, 45.90, 6438.2},
+		{"east along the equator", Point{0, 0}, Point{0, 90}, 90, halfCircumference / 2},
+		{"west along the equator", Point{0, 0}, Point{0, -90}, 270, halfCircumference / 2},
+		{"north", Point{0, 0}, Point{10, 0}, 0, halfCircumference / 18},
+		{"south", Point{0, 0}, Point{-10, 0}, 180, halfCircumference / 18},
+		{"across the date line", Point{0, 179}, Point{0, -179}, 90, halfCircumference / 90},
+		{"same point", jo62, jo62, 0, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if b := Bearing(tc.from, tc.to); !near(b, tc.bearing, 0.01) {
+				t.Errorf("got bearing %.2f°, want %.2f°", b, tc.bearing)
+			}
+			if d := Distance(tc.from, tc.to); !near(d, tc.distance, 0.1) {
+				t.Errorf("got distance %.1f km, want %.1f km", d, tc.distance)
+			}
+		})
+	}
+}
+
+func TestAntipodes(t *testing.T) {
+
+	// the bearing to the antipode is undefined, but it has to be a valid
+	// heading; the distance is half the circumference
+	tests := []struct {
+		from, to Point
+	}{
+		{Point{52.5, 13}, Point{-52.5, -167}},
+		{Point{0, 0}, Point{0, 180}},
+		{Point{90, 0}, Point{-90, 0}},
+	}
+
+	for _, tc := range tests {
+		b := Bearing(tc.from, tc.to)
+		if math.IsNaN(b) || b < 0 || b >= 360 {
+			t.Errorf("%v to %v: invalid bearing %f", tc.from, tc.to, b)
+		}
+		if d := Distance(tc.from, tc.to); !near(d, math.Pi*earthRadius, 0.1) {
+			t.Errorf("%v to %v: got distance %.1f km, want %.1f km", tc.from, tc.to, d, math.Pi*earthRadius)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package hub
+
+import (
+	"sync"
+	"time"
+)
+
+// headingCoalescer limits the rate of the heading events of each rotator.
+// The first update within a window is broadcasted immediately, the
+// following ones are merged and only the latest one is broadcasted
+// at the end of the window.
+//
+// The events are sent while holding the lock, so that an update which is
+// sent at the start of a window can't overtake the latest update sent by
+// the flush at its end. send must therefore not block and must not call
+// back into the coalescer.
+type headingCoalescer struct {
+	sync.Mutex
+	window    time.Duration
+	pending   map[string]*Event // key: rotator name; nil: window open, nothing pending
+	send      func(Event)
+	afterFunc func(time.Duration, func()) // calls f once the window has elapsed; replaced by the tests
+}
+
+func newHeadingCoalescer(send func(Event)) *headingCoalescer {
+	return &headingCoalescer{
+		pending: make(map[string]*Event),
+		send:    send,
+		afterFunc: func(d time.Duration, f func()) {
+			time.AfterFunc(d, f)
+		},
+	}
+}
+
+func (c *headingCoalescer) setWindow(window time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.window = window
+}
+
+func (c *headingCoalescer) add(ev Event) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.window <= 0 {
+		c.send(ev)
+		return
+	}
+
+	if _, open := c.pending[ev.DeviceName]; open {
+		c.pending[ev.DeviceName] = &ev // latest wins
+		return
+	}
+
+	c.pending[ev.DeviceName] = nil
+	c.afterFunc(c.window, func() { c.flush(ev.DeviceName) })
+	c.send(ev)
+}
+
+// flush is called at the end of a window. If updates have been merged,
+// the latest one is broadcasted and a new window is opened.
+func (c *headingCoalescer) flush(name string) {
+	c.Lock()
+	defer c.Unlock()
+
+	ev, open := c.pending[name]
+	if !open {
+		return // rotator has been removed
+	}
+	if ev == nil {
+		delete(c.pending, name)
+		return
+	}
+	c.pending[name] = nil
+	c.afterFunc(c.window, func() { c.flush(name) })
+	c.send(*ev)
+}
+
+// forget drops the pending update of a rotator.
+func (c *headingCoalescer) forget(name string) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.pending, name)
+}
@@ -0,0 +1,314 @@
+package hub
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/touchctl/internal/rotatortest"
+)
+
+// recorder collects the events sent by a headingCoalescer.
+type recorder struct {
+	sync.Mutex
+	events []Event
+}
+
+func (r *recorder) send(ev Event) {
+	r.Lock()
+	defer r.Unlock()
+	r.events = append(r.events, ev)
+}
+
+func (r *recorder) azimuths() []int {
+	r.Lock()
+	defer r.Unlock()
+	az := []int{}
+	for _, ev := range r.events {
+		az = append(az, ev.Heading.Azimuth)
+	}
+	return az
+}
+
+func headingEvent(name string, az int) Event {
+	return Event{
+		Name:       UpdateHeading,
+		DeviceName: name,
+		Heading:    &rotator.Heading{Azimuth: az},
+	}
+}
+
+// fakeClock replaces time.AfterFunc in the headingCoalescer. The timers
+// only fire when the test advances the clock.
+type fakeClock struct {
+	sync.Mutex
+	now    time.Duration
+	timers []fakeTimer
+	fired  int
+}
+
+type fakeTimer struct {
+	at time.Duration
+	f  func()
+}
+
+func (c *fakeClock) afterFunc(d time.Duration, f func()) {
+	c.Lock()
+	defer c.Unlock()
+	c.timers = append(c.timers, fakeTimer{at: c.now + d, f: f})
+}
+
+// advance moves the clock forward and calls the functions of the
+// expired timers in the order of their expiry.
+func (c *fakeClock) advance(d time.Duration) {
+	c.Lock()
+	end := c.now + d
+	for {
+		next := -1
+		for i, t := range c.timers {
+			if t.at <= end && (next < 0 || t.at < c.timers[next].at) {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+		t := c.timers[next]
+		c.timers = append(c.timers[:next], c.timers[next+1:]...)
+		c.now = t.at
+		c.fired++
+		c.Unlock()
+		t.f()
+		c.Lock()
+	}
+	c.now = end
+	c.Unlock()
+}
+
+func TestCoalescerCalls(t *testing.T) {
+
+	tt := []struct {
+		name    string
+		window  time.Duration
+		step    time.Duration // between two updates of a rotator
+		updates int
+		want    []int // azimuths sent per rotator
+	}{
+		{
+			name:    "no window",
+			window:  0,
+			step:    time.Millisecond * 10,
+			updates: 5,
+			want:    []int{0, 1, 2, 3, 4},
+		},
+		{
+			name:    "updates faster than the window",
+			window:  time.Millisecond * 50,
+			step:    time.Millisecond * 10,
+			updates: 23,
+			want:    []int{0, 4, 9, 14, 19, 22},
+		},
+		{
+			name:    "updates as fast as the window",
+			window:  time.Millisecond * 50,
+			step:    time.Millisecond * 50,
+			updates: 4,
+			want:    []int{0, 1, 2, 3},
+		},
+		{
+			name:    "updates slower than the window",
+			window:  time.Millisecond * 50,
+			step:    time.Millisecond * 200,
+			updates: 4,
+			want:    []int{0, 1, 2, 3},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := &fakeClock{}
+			yagi, dipole := &recorder{}, &recorder{}
+			c := newHeadingCoalescer(func(ev Event) {
+				if ev.DeviceName == "Yagi" {
+					yagi.send(ev)
+				} else {
+					dipole.send(ev)
+				}
+			})
+			c.afterFunc = clock.afterFunc
+			c.setWindow(tc.window)
+
+			for i := 0; i < tc.updates; i++ {
+				c.add(headingEvent("Yagi", i))
+				c.add(headingEvent("Dipole", i))
+				clock.advance(tc.step)
+			}
+			clock.advance(tc.window * 2)
+
+			for _, r := range []*recorder{yagi, dipole} {
+				if az := r.azimuths(); fmt.Sprint(az) != fmt.Sprint(tc.want) {
+					t.Fatalf("got %v, want %v", az, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCoalescerForget(t *testing.T) {
+
+	clock := &fakeClock{}
+	r := &recorder{}
+	c := newHeadingCoalescer(r.send)
+	c.afterFunc = clock.afterFunc
+	c.setWindow(time.Millisecond * 50)
+
+	c.add(headingEvent("Yagi", 1))
+	c.add(headingEvent("Yagi", 2))
+	c.forget("Yagi")
+	clock.advance(time.Millisecond * 100)
+	if az := r.azimuths(); fmt.Sprint(az) != "[1]" {
+		t.Fatalf("got %v, want [1]", az)
+	}
+
+	// a rotator which has been added again starts with a new window
+	c.add(headingEvent("Yagi", 3))
+	if az := r.azimuths(); fmt.Sprint(az) != "[1 3]" {
+		t.Fatalf("got %v, want [1 3]", az)
+	}
+}
+
+func TestCoalescerLatestWins(t *testing.T) {
+
+	// sending the first update of the window takes longer than the
+	// window; the flush must not overtake it
+	r := &recorder{}
+	first := true
+	c := newHeadingCoalescer(func(ev Event) {
+		if first {
+			first = false
+			time.Sleep(time.Millisecond * 50)
+		}
+		r.send(ev)
+	})
+	c.setWindow(time.Millisecond * 5)
+
+	done := make(chan struct{})
+	go func() {
+		c.add(headingEvent("Yagi", 1))
+		close(done)
+	}()
+
+	time.Sleep(time.Millisecond * 10)
+	c.add(headingEvent("Yagi", 2))
+	<-done
+
+	time.Sleep(time.Millisecond * 100)
+	az := r.azimuths()
+	if len(az) == 0 || az[len(az)-1] != 2 {
+		t.Fatalf("got %v, the latest heading (2) must be sent last", az)
+	}
+}
+
+// The heading benchmarks simulate rotators which report their heading
+// every 10ms to three stack pages. An op is one round in which every
+// rotator reports once; the deliveries of a round are awaited before the
+// next one starts, so that the metrics don't depend on the scheduler.
+// draws/op are the handler calls (each one re-renders a label), goroutines/op
+// the go routines which were started.
+const (
+	benchRotators = 4
+	benchPages    = 3
+	benchStep     = time.Millisecond * 10
+)
+
+// BenchmarkHeadingEventsHandlers measures the former event path, which
+// started a go routine per handler and heading update.
+func BenchmarkHeadingEventsHandlers(b *testing.B) {
+
+	var draws, goroutines int64
+	var wg sync.WaitGroup
+
+	handlers := make([]func(r rotator.Rotator, status rotator.Heading), benchPages)
+	for i := range handlers {
+		handlers[i] = func(r rotator.Rotator, status rotator.Heading) {
+			defer wg.Done()
+			atomic.AddInt64(&draws, 1)
+		}
+	}
+
+	rs := make([]*rotatortest.Fake, benchRotators)
+	for i := range rs {
+		rs[i] = rotatortest.New(fmt.Sprintf("rotator%d", i), rotator.Config{})
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, r := range rs {
+			for _, handler := range handlers {
+				wg.Add(1)
+				goroutines++
+				go handler(r, rotator.Heading{Azimuth: n % 360})
+			}
+		}
+		wg.Wait()
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(draws)/float64(b.N), "draws/op")
+	b.ReportMetric(float64(goroutines)/float64(b.N), "goroutines/op")
+}
+
+// BenchmarkHeadingEvents measures the hub's subscriptions with and
+// without a heading window.
+func BenchmarkHeadingEvents(b *testing.B) {
+
+	for _, window := range []time.Duration{0, time.Millisecond * 50} {
+		b.Run(fmt.Sprintf("window=%v", window), func(b *testing.B) {
+			hub, err := NewHub()
+			if err != nil {
+				b.Fatal(err)
+			}
+			clock := &fakeClock{}
+			hub.headings.afterFunc = clock.afterFunc
+			hub.SetHeadingWindow(window)
+
+			// a heading of the device "sync" marks the end of a round
+			var draws int64
+			var wg sync.WaitGroup
+			for i := 0; i < benchPages; i++ {
+				s := hub.Subscribe(func(ev Event) {
+					if ev.DeviceName == "sync" {
+						wg.Done()
+						return
+					}
+					atomic.AddInt64(&draws, 1)
+				}, UpdateHeading)
+				defer hub.Unsubscribe(s)
+			}
+
+			rs := make([]*rotatortest.Fake, benchRotators)
+			for i := range rs {
+				rs[i] = rotatortest.New(fmt.Sprintf("rotator%d", i), rotator.Config{})
+			}
+
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				for _, r := range rs {
+					hub.BroadcastHeading(r, rotator.Heading{Azimuth: n % 360})
+				}
+				clock.advance(benchStep)
+				wg.Add(benchPages)
+				hub.notify(Event{Name: UpdateHeading, DeviceName: "sync"})
+				wg.Wait()
+			}
+			b.StopTimer()
+
+			// the timers of time.AfterFunc run in their own go routine
+			b.ReportMetric(float64(draws)/float64(b.N), "draws/op")
+			b.ReportMetric(float64(clock.fired)/float64(b.N), "goroutines/op")
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package hub
+
+import (
+	"log"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	Switch "github.com/dh1tw/remoteSwitch/switch"
+)
+
+// Event is sent to the subscribers and the websocket clients whenever
+// a device has been added, removed or updated.
+type Event struct {
+	Name       EventName        `json:"name,omitempty"`
+	DeviceName string           `json:"device_name,omitempty"`
+	Heading    *rotator.Heading `json:"heading,omitempty"` // rotators only
+	Device     *Switch.Device   `json:"device,omitempty"`  // switches only
+	Stale      bool             `json:"stale"`             // see Hub.SetStale
+	Rotator    rotator.Rotator  `json:"-"`                 // rotators only
+	Switch     Switch.Switcher  `json:"-"`                 // switches only
+}
+
+// EventName is the type of an Event.
+type EventName string
+
+const (
+	AddRotator    EventName = "add_rotator"
+	RemoveRotator EventName = "remove_rotator"
+	UpdateHeading EventName = "heading"
+	AddSwitch     EventName = "add_switch"
+	RemoveSwitch  EventName = "remove_switch"
+	UpdateSwitch  EventName = "update_switch" // the state of a port has changed
+	UpdateStale   EventName = "stale"         // the liveness of a device has changed
+)
+
+// BroadcastHeading sends the heading of a rotator to all subscribers and
+// connected clients, subject to the heading window (see SetHeadingWindow).
+// It satisfies rotator.EventHandler.
+func (hub *Hub) BroadcastHeading(r rotator.Rotator, heading rotator.Heading) {
+	hub.headings.add(Event{
+		Name:       UpdateHeading,
+		DeviceName: r.Name(),
+		Heading:    &heading,
+		Rotator:    r,
+	})
+}
+
+// BroadcastSwitch sends the state of a switch to all subscribers and
+// connected clients.
+func (hub *Hub) BroadcastSwitch(s Switch.Switcher, device Switch.Device) {
+	hub.Broadcast(Event{
+		Name:       UpdateSwitch,
+		DeviceName: device.Name,
+		Device:     &device,
+		Switch:     s,
+	})
+}
+
+// Broadcast sends an event to all subscribers and connected clients
+func (hub *Hub) Broadcast(ev Event) {
+	hub.Lock()
+	defer hub.Unlock()
+	hub.broadcast(ev)
+}
+
+// broadcast queues the event for the websocket clients and the
+// subscribers. Since the event is queued while the caller holds the
+// lock, the events reach them in the same order as the changes of the
+// hub's state.
+func (hub *Hub) broadcast(ev Event) {
+	hub.broadcastToWsClients(ev)
+	hub.notify(ev)
+}
+
+// broadcastToWsClients queues the event for every websocket client.
+// It never blocks; a client whose queue is full is disconnected. The
+// caller must hold the lock.
+func (hub *Hub) broadcastToWsClients(event Event) {
+
+	for c := range hub.wsClients {
+		select {
+		case c.send <- event:
+		default:
+			log.Printf("client %v too slow, disconnecting\n", c.RemoteAddr())
+			hub.dropWsClient(c)
+		}
+	}
+}
+
+// snapshot returns an add event for each registered device, so that
+// a new client starts with the current state. The caller must hold the lock.
+func (hub *Hub) snapshot() []Event {
+	events := make([]Event, 0, len(hub.rotators)+len(hub.switches))
+
+	for _, r := range hub.rotators {
+		heading := r.Serialize().Heading
+		events = append(events, Event{
+			Name:       AddRotator,
+			DeviceName: r.Name(),
+			Heading:    &heading,
+			Stale:      hub.stale[r.Name()],
+		})
+	}
+
+	for _, s := range hub.switches {
+		device := s.Serialize()
+		events = append(events, Event{
+			Name:       AddSwitch,
+			DeviceName: s.Name(),
+			Device:     &device,
+			Stale:      hub.stale[s.Name()],
+		})
+	}
+
+	return events
+}
@@ -0,0 +1,347 @@
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	Switch "github.com/dh1tw/remoteSwitch/switch"
+	"github.com/dh1tw/touchctl/azimuth"
+	"github.com/dh1tw/touchctl/tx"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+func (hub *Hub) wsHandler(w http.ResponseWriter, r *http.Request) {
+
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     hub.checkOrigin,
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	hub.addWsClient(conn)
+}
+
+// checkOrigin accepts websocket connections from pages served by this
+// server, from the origins allowed with SetAllowedOrigins and from
+// clients which don't send an Origin header (i.e. no browsers).
+func (hub *Hub) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	hub.RLock()
+	defer hub.RUnlock()
+	for _, o := range hub.origins {
+		if strings.EqualFold(strings.TrimSpace(o), origin) {
+			return true
+		}
+	}
+
+	log.Printf("websocket connection from origin %s refused\n", origin)
+	return false
+}
+
+// devices is the response of the devices endpoint.
+type devices struct {
+	Rotators rotator.Objects          `json:"rotators"`
+	Switches map[string]Switch.Device `json:"switches"`
+}
+
+func (hub *Hub) devicesHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	d := devices{
+		Rotators: hub.serializeRotators(),
+		Switches: hub.serializeSwitches(),
+	}
+
+	if err := json.NewEncoder(w).Encode(d); err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to encode devices msg"))
+	}
+}
+
+func (hub *Hub) rotatorsHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	if err := json.NewEncoder(w).Encode(hub.serializeRotators()); err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to encode rotators msg"))
+	}
+}
+
+func (hub *Hub) rotatorHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	vars := mux.Vars(req)
+	rName := vars["rotator"]
+
+	r, ok := hub.Rotator(rName)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("unable to find rotator"))
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(r.Serialize()); err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to encode rotator data to json"))
+	}
+}
+
+func (hub *Hub) headingHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	vars := mux.Vars(req)
+	rName := vars["rotator"]
+
+	r, ok := hub.Rotator(rName)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("unable to find rotator"))
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(r.Serialize().Heading); err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to encode heading to json"))
+	}
+}
+
+func (hub *Hub) azimuthHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	vars := mux.Vars(req)
+	rName := vars["rotator"]
+
+	r, ok := hub.Rotator(rName)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("unable to find rotator"))
+		return
+	}
+
+	azPOST := rotator.AzimuthPut{}
+	dec := json.NewDecoder(req.Body)
+
+	if err := dec.Decode(&azPOST); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid json"))
+		return
+	}
+
+	if azPOST.Azimuth == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid request"))
+		return
+	}
+
+	if !r.HasAzimuth() {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("rotator does not support azimuth"))
+		return
+	}
+
+	if err := azimuth.Check(r.Serialize().Config, *azPOST.Azimuth); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if err := hub.interlock().Check(); err != nil {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if err := r.SetAzimuth(*azPOST.Azimuth); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(fmt.Sprintf("unable to set azimuth to %v: %s", *azPOST.Azimuth, err)))
+	}
+}
+
+func (hub *Hub) switchesHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	if err := json.NewEncoder(w).Encode(hub.serializeSwitches()); err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to encode switches msg"))
+	}
+}
+
+func (hub *Hub) switchHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	vars := mux.Vars(req)
+	sName := vars["switch"]
+
+	s, ok := hub.Switch(sName)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("unable to find switch"))
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(s.Serialize()); err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to encode switch data to json"))
+	}
+}
+
+func (hub *Hub) portHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	vars := mux.Vars(req)
+	sName := vars["switch"]
+	sPort := vars["port"]
+
+	s, ok := hub.Switch(sName)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("unable to find switch"))
+		return
+	}
+
+	p, err := s.GetPort(sPort)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("unable to find port"))
+		return
+	}
+
+	switch req.Method {
+	case "GET":
+		if err := json.NewEncoder(w).Encode(p); err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("unable to encode port to json"))
+		}
+
+	case "PUT":
+		p := Switch.Port{}
+		dec := json.NewDecoder(req.Body)
+
+		if err := dec.Decode(&p); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid json"))
+			return
+		}
+
+		// the port name is taken from the URL
+		p.Name = sPort
+
+		if len(p.Terminals) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid request"))
+			return
+		}
+
+		if err := hub.interlock().Check(); err != nil {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// the same policies apply as on the Stream Deck
+		if err := hub.checkPort(s, p); err != nil {
+			if errors.Is(err, ErrRefused) {
+				w.WriteHeader(http.StatusConflict)
+			} else {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if err := s.SetPort(p); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(fmt.Sprintf("unable to set port %s: %s", p.Name, err)))
+			return
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (hub *Hub) serializeRotators() rotator.Objects {
+	hub.RLock()
+	defer hub.RUnlock()
+
+	rs := rotator.Objects{}
+
+	for _, r := range hub.rotators {
+		sr := r.Serialize()
+		rs[sr.Name] = sr
+	}
+
+	return rs
+}
+
+func (hub *Hub) serializeSwitches() map[string]Switch.Device {
+	hub.RLock()
+	defer hub.RUnlock()
+
+	ss := make(map[string]Switch.Device)
+
+	for _, s := range hub.switches {
+		sd := s.Serialize()
+		ss[sd.Name] = sd
+	}
+
+	return ss
+}
+
+// checkPort runs the port guard (see SetPortGuard) on a request to set
+// the port p of the switch s.
+func (hub *Hub) checkPort(s Switch.Switcher, p Switch.Port) error {
+	hub.RLock()
+	guard := hub.portGuard
+	hub.RUnlock()
+
+	if guard == nil {
+		return nil
+	}
+	return guard(s, p)
+}
+
+func (hub *Hub) interlock() *tx.Interlock {
+	hub.RLock()
+	defer hub.RUnlock()
+	return hub.il
+}
@@ -0,0 +1,194 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	Switch "github.com/dh1tw/remoteSwitch/switch"
+	"github.com/dh1tw/touchctl/internal/rotatortest"
+	"github.com/dh1tw/touchctl/internal/switchtest"
+	"github.com/dh1tw/touchctl/tx"
+)
+
+// newTestHub returns a hub with its HTTP routes, a rotator with an
+// overlap of 90° and a stackmatch.
+func newTestHub(t *testing.T) (*Hub, *rotatortest.Fake, *switchtest.Fake) {
+	hub, err := NewHub()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub.Handler()
+
+	r := rotatortest.New("Yagi", rotator.Config{HasAzimuth: true, AzimuthMin: 0, AzimuthMax: 450})
+	if err := hub.AddRotator(r); err != nil {
+		t.Fatal(err)
+	}
+
+	s := switchtest.New("SM20", Switch.Port{
+		Name: "SM",
+		Terminals: []Switch.Terminal{
+			{Name: "Top", State: true},
+			{Name: "Bottom"},
+		},
+	})
+	if err := hub.AddSwitch(s); err != nil {
+		t.Fatal(err)
+	}
+
+	return hub, r, s
+}
+
+func request(hub *Hub, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	hub.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAzimuthHandler(t *testing.T) {
+
+	hub, r, _ := newTestHub(t)
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		preset int
+	}{
+		{"valid", "/api/v1.0/rotator/Yagi/azimuth", `{"azimuth": 120}`, http.StatusOK, 120},
+		{"overlap", "/api/v1.0/rotator/Yagi/azimuth", `{"azimuth": 420}`, http.StatusOK, 420},
+		{"out of range", "/api/v1.0/rotator/Yagi/azimuth", `{"azimuth": 451}`, http.StatusBadRequest, 420},
+		{"negative", "/api/v1.0/rotator/Yagi/azimuth", `{"azimuth": -10}`, http.StatusBadRequest, 420},
+		{"missing azimuth", "/api/v1.0/rotator/Yagi/azimuth", `{}`, http.StatusBadRequest, 420},
+		{"invalid json", "/api/v1.0/rotator/Yagi/azimuth", `{"azimuth":`, http.StatusBadRequest, 420},
+		{"unknown rotator", "/api/v1.0/rotator/Dipole/azimuth", `{"azimuth": 10}`, http.StatusNotFound, 420},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := request(hub, "PUT", tc.path, tc.body)
+			if rec.Code != tc.status {
+				t.Fatalf("got status %d, want %d (%s)", rec.Code, tc.status, rec.Body.String())
+			}
+			if p := r.AzPreset(); p != tc.preset {
+				t.Fatalf("got preset %d, want %d", p, tc.preset)
+			}
+		})
+	}
+}
+
+func TestAzimuthHandlerInterlock(t *testing.T) {
+
+	hub, r, _ := newTestHub(t)
+
+	il := tx.NewInterlock()
+	il.Set("test", true)
+	hub.SetInterlock(il)
+
+	rec := request(hub, "PUT", "/api/v1.0/rotator/Yagi/azimuth", `{"azimuth": 120}`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if p := r.AzPreset(); p != 0 {
+		t.Fatalf("rotator turned to %d while transmitting", p)
+	}
+}
+
+func TestPortHandler(t *testing.T) {
+
+	hub, _, s := newTestHub(t)
+
+	// the guard refuses to switch off the last active terminal, based
+	// on the live state of the switch
+	var guarded []Switch.Port
+	var guardErr error // e.g. the switch can't be reached by the guard
+	hub.SetPortGuard(func(sw Switch.Switcher, p Switch.Port) error {
+		guarded = append(guarded, p)
+		if guardErr != nil {
+			return guardErr
+		}
+		live, err := sw.GetPort(p.Name)
+		if err != nil {
+			return err
+		}
+		active := map[string]bool{}
+		for _, t := range live.Terminals {
+			active[t.Name] = t.State
+		}
+		for _, t := range p.Terminals {
+			active[t.Name] = t.State
+		}
+		for _, on := range active {
+			if on {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w, at least 1 terminal(s) must remain active", ErrRefused)
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		sets   int
+	}{
+		{"get", "GET", "/api/v1.0/switch/SM20/port/SM", "", http.StatusOK, 0},
+		{"unknown switch", "PUT", "/api/v1.0/switch/SM40/port/SM", `{"terminals":[{"name":"Top"}]}`, http.StatusNotFound, 0},
+		{"unknown port", "PUT", "/api/v1.0/switch/SM20/port/X", `{"terminals":[{"name":"Top"}]}`, http.StatusNotFound, 0},
+		{"no terminals", "PUT", "/api/v1.0/switch/SM20/port/SM", `{}`, http.StatusBadRequest, 0},
+		{"invalid json", "PUT", "/api/v1.0/switch/SM20/port/SM", `{"terminals":`, http.StatusBadRequest, 0},
+		{"switch off last terminal", "PUT", "/api/v1.0/switch/SM20/port/SM", `{"terminals":[{"name":"Top"}]}`, http.StatusConflict, 0},
+		{"switch on", "PUT", "/api/v1.0/switch/SM20/port/SM", `{"terminals":[{"name":"Bottom","state":true}]}`, http.StatusOK, 1},
+		{"switch off", "PUT", "/api/v1.0/switch/SM20/port/SM", `{"terminals":[{"name":"Top"}]}`, http.StatusOK, 2},
+		{"switch off again", "PUT", "/api/v1.0/switch/SM20/port/SM", `{"terminals":[{"name":"Bottom"}]}`, http.StatusConflict, 2},
+		{"switch unreachable", "PUT", "/api/v1.0/switch/SM20/port/SM", `{"terminals":[{"name":"Top","state":true}]}`, http.StatusInternalServerError, 2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.status == http.StatusInternalServerError {
+				guardErr = errors.New("connection lost")
+				defer func() { guardErr = nil }()
+			}
+			rec := request(hub, tc.method, tc.path, tc.body)
+			if rec.Code != tc.status {
+				t.Fatalf("got status %d, want %d (%s)", rec.Code, tc.status, rec.Body.String())
+			}
+			if n := s.Sets(); n != tc.sets {
+				t.Fatalf("got %d SetPort calls, want %d", n, tc.sets)
+			}
+		})
+	}
+
+	// the port name is taken from the URL
+	for _, p := range guarded {
+		if p.Name != "SM" {
+			t.Fatalf("guard called with port %s", p.Name)
+		}
+	}
+}
+
+func TestPortHandlerInterlock(t *testing.T) {
+
+	hub, _, s := newTestHub(t)
+
+	il := tx.NewInterlock()
+	il.Set("test", true)
+	hub.SetInterlock(il)
+
+	rec := request(hub, "PUT", "/api/v1.0/switch/SM20/port/SM", `{"terminals":[{"name":"Bottom","state":true}]}`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if n := s.Sets(); n != 0 {
+		t.Fatal("switch set while transmitting")
+	}
+}
@@ -1,28 +1,52 @@
 package hub
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"sync"
+	"time"
 
 	"github.com/dh1tw/remoteRotator/rotator"
 	Switch "github.com/dh1tw/remoteSwitch/switch"
+	"github.com/dh1tw/touchctl/tx"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
 )
 
 // Hub is a struct which makes a rotator available through network
 // interfaces, supporting several protocols.
 type Hub struct {
 	sync.RWMutex
-	rotators map[string]rotator.Rotator //key: Rotator name
-	switches map[string]Switch.Switcher //key: Switch name
+	rotators      map[string]rotator.Rotator //key: Rotator name
+	switches      map[string]Switch.Switcher //key: Switch name
+	stale         map[string]bool            //key: device name
+	longPath      map[string]int             //key: Rotator name, value: long path azimuth
+	wsClients     map[*WsClient]bool
+	closeWsClient chan *WsClient
+	router        *mux.Router
+	il            *tx.Interlock
+	portGuard     PortGuard
+	origins       []string // allowed websocket origins besides our own
+	subscribers   subscribers
+	headings      *headingCoalescer
 }
 
 // NewHub returns the pointer to an initialized Hub object.
 func NewHub(rotators ...rotator.Rotator) (*Hub, error) {
 	hub := &Hub{
-		rotators: make(map[string]rotator.Rotator),
-		switches: make(map[string]Switch.Switcher),
+		rotators:      make(map[string]rotator.Rotator),
+		switches:      make(map[string]Switch.Switcher),
+		stale:         make(map[string]bool),
+		longPath:      make(map[string]int),
+		wsClients:     make(map[*WsClient]bool),
+		closeWsClient: make(chan *WsClient),
+		subscribers: subscribers{
+			subs: make(map[*Subscription]bool),
+		},
 	}
+	hub.headings = newHeadingCoalescer(hub.Broadcast)
 
 	for _, r := range rotators {
 		if err := hub.AddRotator(r); err != nil {
@@ -35,7 +59,55 @@ func NewHub(rotators ...rotator.Rotator) (*Hub, error) {
 	return hub, nil
 }
 
+// SetInterlock sets the TX interlock which is checked before a rotator
+// or a switch is commanded through the HTTP API.
+func (hub *Hub) SetInterlock(il *tx.Interlock) {
+	hub.Lock()
+	defer hub.Unlock()
+	hub.il = il
+}
+
+// PortGuard checks a request to set the port p of the switch s before it
+// is executed through the HTTP API. A non-nil error refuses the request.
+// Errors which wrap ErrRefused (e.g. if the request would violate the
+// switching policy of a stackmatch) are answered with 409 Conflict, all
+// other errors (e.g. if the switch can't be reached) with 500.
+type PortGuard func(s Switch.Switcher, p Switch.Port) error
+
+// ErrRefused is wrapped by the errors of requests which have been refused
+// due to a switching policy.
+var ErrRefused = errors.New("refused")
+
+// SetPortGuard sets the guard which checks the port requests of the HTTP
+// API. A nil guard accepts all requests.
+func (hub *Hub) SetPortGuard(g PortGuard) {
+	hub.Lock()
+	defer hub.Unlock()
+	hub.portGuard = g
+}
+
+// SetAllowedOrigins sets the origins (e.g. "http://dashboard.local:8080")
+// from which browsers may open a websocket connection in addition to the
+// pages served by the hub itself, e.g. if the dashboard is served from
+// another host.
+func (hub *Hub) SetAllowedOrigins(origins []string) {
+	hub.Lock()
+	defer hub.Unlock()
+	hub.origins = origins
+}
+
+// SetHeadingWindow limits the heading events of each rotator to one per
+// window. Updates within the window are merged; the latest heading is
+// always delivered. A window of 0 disables the rate limit.
+func (hub *Hub) SetHeadingWindow(window time.Duration) {
+	hub.headings.setWindow(window)
+}
+
 func (hub *Hub) handleClose() {
+	for {
+		c := <-hub.closeWsClient
+		hub.removeWsClient(c)
+	}
 }
 
 // AddRotator adds / registers a rotator. The rotator's name must be unique.
@@ -60,6 +132,15 @@ func (hub *Hub) addRotator(r rotator.Rotator) error {
 		return fmt.Errorf("rotator names must be unique; %s provided twice", r.Name())
 	}
 	hub.rotators[r.Name()] = r
+	delete(hub.stale, r.Name())
+	heading := r.Serialize().Heading
+	ev := Event{
+		Name:       AddRotator,
+		DeviceName: r.Name(),
+		Heading:    &heading,
+		Rotator:    r,
+	}
+	hub.broadcast(ev)
 	log.Printf("added rotator (%s)\n", r.Name())
 
 	return nil
@@ -71,6 +152,15 @@ func (hub *Hub) addSwitch(s Switch.Switcher) error {
 		return fmt.Errorf("the switch's names must be unique; %s provided twice", s.Name())
 	}
 	hub.switches[s.Name()] = s
+	delete(hub.stale, s.Name())
+	device := s.Serialize()
+	ev := Event{
+		Name:       AddSwitch,
+		DeviceName: s.Name(),
+		Device:     &device,
+		Switch:     s,
+	}
+	hub.broadcast(ev)
 	log.Printf("added switch (%s)\n", s.Name())
 
 	return nil
@@ -78,21 +168,40 @@ func (hub *Hub) addSwitch(s Switch.Switcher) error {
 
 // RemoveRotator deletes / de-registers a rotator.
 func (hub *Hub) RemoveRotator(r rotator.Rotator) {
-	hub.Lock()
-	defer hub.Unlock()
+	ev := Event{
+		Name:       RemoveRotator,
+		DeviceName: r.Name(),
+		Rotator:    r,
+	}
+
+	hub.headings.forget(r.Name())
 
+	hub.Lock()
+	hub.broadcast(ev)
 	r.Close()
 	delete(hub.rotators, r.Name())
+	delete(hub.stale, r.Name())
+	delete(hub.longPath, r.Name())
+	hub.Unlock()
+
 	log.Printf("removed rotator (%s)\n", r.Name())
 }
 
 // RemoveSwitch deletes / de-registers a switch.
 func (hub *Hub) RemoveSwitch(s Switch.Switcher) {
-	hub.Lock()
-	defer hub.Unlock()
+	ev := Event{
+		Name:       RemoveSwitch,
+		DeviceName: s.Name(),
+		Switch:     s,
+	}
 
+	hub.Lock()
+	hub.broadcast(ev)
 	s.Close()
 	delete(hub.switches, s.Name())
+	delete(hub.stale, s.Name())
+	hub.Unlock()
+
 	log.Printf("removed switch (%s)\n", s.Name())
 }
 
@@ -116,6 +225,62 @@ func (hub *Hub) Switch(name string) (Switch.Switcher, bool) {
 	return sw, ok
 }
 
+// SetStale marks a registered device as stale, e.g. because its service
+// has not been seen in the registry for a while. Stale devices remain
+// registered, but their state can not be trusted.
+func (hub *Hub) SetStale(name string, stale bool) {
+	hub.Lock()
+	_, isRotator := hub.rotators[name]
+	_, isSwitch := hub.switches[name]
+	if (!isRotator && !isSwitch) || hub.stale[name] == stale {
+		hub.Unlock()
+		return
+	}
+	if stale {
+		hub.stale[name] = true
+		log.Printf("%s is stale\n", name)
+	} else {
+		delete(hub.stale, name)
+		log.Printf("%s is alive again\n", name)
+	}
+	ev := Event{
+		Name:       UpdateStale,
+		DeviceName: name,
+		Stale:      stale,
+	}
+	hub.broadcast(ev)
+	hub.Unlock()
+}
+
+// Stale returns true if the device has been marked as stale.
+func (hub *Hub) Stale(name string) bool {
+	hub.RLock()
+	defer hub.RUnlock()
+	return hub.stale[name]
+}
+
+// SetLongPath records whether the rotator has been turned to az on the
+// long path.
+func (hub *Hub) SetLongPath(name string, az int, longPath bool) {
+	hub.Lock()
+	defer hub.Unlock()
+	if !longPath {
+		delete(hub.longPath, name)
+		return
+	}
+	hub.longPath[name] = az
+}
+
+// LongPath returns true if the rotator has been turned to preset on the
+// long path. As soon as the rotator is turned to another heading, the
+// long path indication is dropped.
+func (hub *Hub) LongPath(name string, preset int) bool {
+	hub.RLock()
+	defer hub.RUnlock()
+	az, ok := hub.longPath[name]
+	return ok && az == preset
+}
+
 // Rotators returns a slice of all registered rotators.
 func (hub *Hub) Rotators() []rotator.Rotator {
 	hub.RLock()
@@ -142,52 +307,75 @@ func (hub *Hub) Switches() []Switch.Switcher {
 	return switches
 }
 
-type Event struct {
-	Name       SwitchEvent `json:"name,omitempty"`
-	DeviceName string      `json:"device_name,omitempty"`
-	Device     sw.Device   `json:"device,omitempty"` //only used for updates
-}
+// addWsClient queues the current state of all devices for a new
+// websocket client and registers it for the subsequent events.
+func (hub *Hub) addWsClient(conn *websocket.Conn) {
+	hub.Lock()
+	defer hub.Unlock()
 
-type SwitchEvent string
+	snapshot := hub.snapshot()
+	client := newWsClient(conn, len(snapshot))
+	for _, ev := range snapshot {
+		client.send <- ev
+	}
 
-const (
-	AddSwitch    SwitchEvent = "add"
-	RemoveSwitch SwitchEvent = "remove"
-	UpdateSwitch SwitchEvent = "update"
-)
+	hub.wsClients[client] = true
 
-// Broadcast sends a rotator Status struct to all connected clients
-func (hub *Hub) Broadcast(dev sw.Device) {
+	go client.writeEvents()
 
-	ev := Event{
-		Name:       UpdateSwitch,
-		DeviceName: dev.Name,
-		Device:     dev,
-	}
-	if err := hub.BroadcastToWsClients(ev); err != nil {
-		log.Println(err)
-	}
+	// we need to listen on the websocket so that the incoming ping
+	// messages can be (automatically) answered (with a pong message)
+	go client.listen(hub.closeWsClient)
+
+	log.Printf("websocket client connected (%v)\n", client.RemoteAddr())
 }
 
-// BroadcastToWsClients will send a rotator.Status struct to all clients
-// connected through a Websocket
-func (hub *Hub) BroadcastToWsClients(event Event) error {
+// removeWsClient removes a websocket client
+func (hub *Hub) removeWsClient(c *WsClient) {
 	hub.Lock()
 	defer hub.Unlock()
 
-	return hub.broadcastToWsClients(event)
+	hub.dropWsClient(c)
+	log.Printf("websocket client disconnected (%v)\n", c.RemoteAddr())
 }
 
-func (hub *Hub) broadcastToWsClients(event Event) error {
+// dropWsClient unregisters a websocket client, stops its sender and
+// closes the connection. The caller must hold the lock.
+func (hub *Hub) dropWsClient(c *WsClient) {
+	if hub.wsClients[c] {
+		delete(hub.wsClients, c)
+		close(c.send)
+	}
+	c.Close()
+}
 
-	for c := range hub.wsClients {
-		if err := c.write(event); err != nil {
-			log.Printf("error writing to client %v: %v\n", c.RemoteAddr(), err)
-			log.Printf("disconnecting client %v\n", c.RemoteAddr())
-			c.Close()
-			delete(hub.wsClients, c)
-		}
+// ListenHTTP starts a HTTP Server on a given network adapter / port and
+// sets the HTTP and Websocket handlers.
+// Since this function contains an endless loop, it should be executed
+// in a go routine. If the listener can not be initialized, it will
+// close the errorCh channel.
+func (hub *Hub) ListenHTTP(host string, port int, errorCh chan<- struct{}) {
+
+	defer close(errorCh)
+
+	handler := hub.Handler()
+
+	// Listen for incoming connections.
+	log.Printf("listening on %s:%d for HTTP connections\n", host, port)
+
+	err := http.ListenAndServe(fmt.Sprintf("%s:%d", host, port), handler)
+	if err != nil {
+		log.Println(err)
+		return
 	}
+}
 
-	return nil
+// Handler returns the HTTP and Websocket handlers of the hub.
+func (hub *Hub) Handler() http.Handler {
+	hub.router = mux.NewRouter().StrictSlash(true)
+
+	// load the HTTP routes with their respective endpoints
+	hub.routes()
+
+	return hub.router
 }
@@ -0,0 +1,15 @@
+package hub
+
+func (hub *Hub) routes() {
+	// API v1.0
+	hub.router.HandleFunc("/api/v1.0/devices", hub.devicesHandler).Methods("GET")
+	hub.router.HandleFunc("/api/v1.0/rotators", hub.rotatorsHandler).Methods("GET")
+	hub.router.HandleFunc("/api/v1.0/rotator/{rotator}", hub.rotatorHandler).Methods("GET")
+	hub.router.HandleFunc("/api/v1.0/rotator/{rotator}/heading", hub.headingHandler).Methods("GET")
+	hub.router.HandleFunc("/api/v1.0/rotator/{rotator}/azimuth", hub.azimuthHandler).Methods("POST", "PUT")
+	hub.router.HandleFunc("/api/v1.0/switches", hub.switchesHandler).Methods("GET")
+	hub.router.HandleFunc("/api/v1.0/switch/{switch}", hub.switchHandler).Methods("GET")
+	hub.router.HandleFunc("/api/v1.0/switch/{switch}/port/{port}", hub.portHandler).Methods("GET", "PUT")
+
+	hub.router.HandleFunc("/ws", hub.wsHandler)
+}
@@ -0,0 +1,158 @@
+package hub
+
+import (
+	"sync"
+)
+
+// subscriptionBuffer is the number of events which are queued for a
+// subscriber before updates get coalesced (see Subscription).
+const subscriptionBuffer = 64
+
+// Subscription delivers the events of the hub to a handler. The events
+// are delivered in order, one at a time, from a dedicated go routine.
+//
+// The publisher never blocks on a slow handler. Once subscriptionBuffer
+// events are queued, a heading, switch or stale update replaces the
+// queued update of the same kind for the same device, unless another
+// event of the device has been queued since. The handler still receives
+// the latest state of every device, and the events of a device are never
+// reordered. Add and remove events are never dropped.
+type Subscription struct {
+	sync.Mutex
+	handler func(Event)
+	names   map[EventName]bool // empty: all events
+	queue   []Event
+	closed  bool
+	cond    *sync.Cond
+}
+
+func newSubscription(handler func(Event), names []EventName) *Subscription {
+	s := &Subscription{
+		handler: handler,
+		names:   make(map[EventName]bool),
+	}
+	s.cond = sync.NewCond(s)
+	for _, n := range names {
+		s.names[n] = true
+	}
+	return s
+}
+
+func (s *Subscription) wants(ev Event) bool {
+	return len(s.names) == 0 || s.names[ev.Name]
+}
+
+// coalescable returns true for the events which only carry the latest
+// state of a device.
+func coalescable(name EventName) bool {
+	switch name {
+	case UpdateHeading, UpdateSwitch, UpdateStale:
+		return true
+	}
+	return false
+}
+
+// deliver queues an event without blocking.
+func (s *Subscription) deliver(ev Event) {
+	s.Lock()
+	defer s.Unlock()
+	if s.closed {
+		return
+	}
+
+	if len(s.queue) >= subscriptionBuffer && coalescable(ev.Name) {
+		// replace the most recent event of the device if it is an
+		// update of the same kind; an update must not overtake any
+		// other event of its device (e.g. an add or remove)
+		for i := len(s.queue) - 1; i >= 0; i-- {
+			if s.queue[i].DeviceName != ev.DeviceName {
+				continue
+			}
+			if s.queue[i].Name == ev.Name {
+				s.queue[i] = ev
+				return
+			}
+			break
+		}
+	}
+
+	s.queue = append(s.queue, ev)
+	s.cond.Signal()
+}
+
+// close stops the subscription once the queued events are delivered.
+func (s *Subscription) close() {
+	s.Lock()
+	defer s.Unlock()
+	s.closed = true
+	s.cond.Signal()
+}
+
+func (s *Subscription) run() {
+	s.Lock()
+	for {
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if len(s.queue) == 0 {
+			s.Unlock()
+			return
+		}
+		ev := s.queue[0]
+		s.queue[0] = Event{}
+		s.queue = s.queue[1:]
+		s.Unlock()
+
+		s.handler(ev)
+
+		s.Lock()
+	}
+}
+
+// subscribers keeps track of the subscriptions of a hub.
+type subscribers struct {
+	sync.RWMutex
+	subs map[*Subscription]bool
+}
+
+// Subscribe registers a handler for the given events. If no event names
+// are provided, the handler receives all events. The handler must not
+// call Subscribe or Unsubscribe.
+func (hub *Hub) Subscribe(handler func(Event), names ...EventName) *Subscription {
+	s := newSubscription(handler, names)
+
+	hub.subscribers.Lock()
+	defer hub.subscribers.Unlock()
+	hub.subscribers.subs[s] = true
+
+	go s.run()
+
+	return s
+}
+
+// Unsubscribe removes a subscription. Events which have already been
+// queued are still delivered.
+func (hub *Hub) Unsubscribe(s *Subscription) {
+	hub.subscribers.Lock()
+	defer hub.subscribers.Unlock()
+
+	if !hub.subscribers.subs[s] {
+		return
+	}
+	delete(hub.subscribers.subs, s)
+	s.close()
+}
+
+// notify queues an event for all interested subscribers. Queueing never
+// blocks, so that a slow subscriber can't hold up the publisher, which
+// calls notify while holding the hub's lock.
+func (hub *Hub) notify(ev Event) {
+	hub.subscribers.RLock()
+	defer hub.subscribers.RUnlock()
+
+	for s := range hub.subscribers.subs {
+		if s.wants(ev) {
+			s.deliver(ev)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package hub
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/touchctl/internal/switchtest"
+)
+
+func TestNotifySlowSubscriber(t *testing.T) {
+
+	hub, err := NewHub()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	received := []Event{}
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	s := hub.Subscribe(func(ev Event) {
+		<-release
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, ev)
+		if ev.Name == RemoveRotator {
+			close(done)
+		}
+	})
+
+	// the handler blocks, notify must not
+	finished := make(chan struct{})
+	go func() {
+		hub.notify(Event{Name: AddRotator, DeviceName: "Yagi"})
+		for i := 0; i < 1000; i++ {
+			hub.notify(Event{Name: UpdateHeading, DeviceName: "Yagi", Heading: &rotator.Heading{Azimuth: i % 360}})
+			hub.notify(Event{Name: UpdateHeading, DeviceName: "Dipole", Heading: &rotator.Heading{Azimuth: 1000 + i}})
+		}
+		hub.notify(Event{Name: RemoveRotator, DeviceName: "Yagi"})
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second * 2):
+		t.Fatal("notify blocked by a slow subscriber")
+	}
+
+	// Subscribe / Unsubscribe don't wait for the subscriber either
+	s2 := hub.Subscribe(func(Event) {})
+	hub.Unsubscribe(s2)
+
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(time.Second * 2):
+		t.Fatal("timeout waiting for the remove event")
+	}
+	hub.Unsubscribe(s)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(received) > subscriptionBuffer+2 {
+		t.Fatalf("%d events delivered, updates not coalesced", len(received))
+	}
+	if received[0].Name != AddRotator {
+		t.Fatalf("first event %s, want %s", received[0].Name, AddRotator)
+	}
+
+	// the latest heading of each rotator is delivered before the removal
+	latest := map[string]int{}
+	for _, ev := range received[:len(received)-1] {
+		if ev.Name == UpdateHeading {
+			latest[ev.DeviceName] = ev.Heading.Azimuth
+		}
+	}
+	if latest["Yagi"] != 999%360 || latest["Dipole"] != 1999 {
+		t.Fatalf("latest headings %v, want Yagi: %d, Dipole: 1999", latest, 999%360)
+	}
+}
+
+func TestUnsubscribeDeliversQueuedEvents(t *testing.T) {
+
+	hub, err := NewHub()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan Event, 10)
+	release := make(chan struct{})
+	s := hub.Subscribe(func(ev Event) {
+		<-release
+		events <- ev
+	}, AddSwitch)
+
+	hub.notify(Event{Name: AddSwitch, DeviceName: "SM20"})
+	hub.notify(Event{Name: UpdateSwitch, DeviceName: "SM20"}) // not subscribed
+	hub.notify(Event{Name: AddSwitch, DeviceName: "SM40"})
+	hub.Unsubscribe(s)
+	hub.notify(Event{Name: AddSwitch, DeviceName: "SM80"}) // after Unsubscribe
+	close(release)
+
+	for _, want := range []string{"SM20", "SM40"} {
+		select {
+		case ev := <-events:
+			if ev.DeviceName != want {
+				t.Fatalf("got %s, want %s", ev.DeviceName, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %s", want)
+		}
+	}
+
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event %s %s", ev.Name, ev.DeviceName)
+	case <-time.After(time.Millisecond * 100):
+	}
+}
+
+func TestDeliverKeepsDeviceOrder(t *testing.T) {
+
+	// the subscription isn't running, so that the events stay queued
+	s := newSubscription(func(Event) {}, nil)
+	for i := 0; i < subscriptionBuffer; i++ {
+		s.deliver(Event{Name: UpdateHeading, DeviceName: "Dipole", Heading: &rotator.Heading{Azimuth: i}})
+	}
+
+	heading := func(az int) Event {
+		return Event{Name: UpdateHeading, DeviceName: "Yagi", Heading: &rotator.Heading{Azimuth: az}}
+	}
+	s.deliver(heading(1))
+	s.deliver(Event{Name: RemoveRotator, DeviceName: "Yagi"})
+	s.deliver(Event{Name: AddRotator, DeviceName: "Yagi"})
+	s.deliver(heading(2))
+	s.deliver(heading(3)) // replaces 2
+	s.deliver(Event{Name: UpdateStale, DeviceName: "Yagi", Stale: true})
+	s.deliver(heading(4))
+
+	got := []string{}
+	for _, ev := range s.queue {
+		if ev.DeviceName != "Yagi" {
+			continue
+		}
+		name := string(ev.Name)
+		if ev.Name == UpdateHeading {
+			name += fmt.Sprintf("(%d)", ev.Heading.Azimuth)
+		}
+		got = append(got, name)
+	}
+
+	want := []string{"heading(1)", "remove_rotator", "add_rotator", "heading(3)", "stale", "heading(4)"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestNotifyFollowsHubState(t *testing.T) {
+
+	hub, err := NewHub()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hub.AddSwitch(switchtest.New("SM20")); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	received := []bool{}
+	done := make(chan struct{})
+	s := hub.Subscribe(func(ev Event) {
+		if ev.DeviceName == "end" {
+			done <- struct{}{}
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, ev.Stale)
+	}, UpdateStale)
+	defer hub.Unsubscribe(s)
+
+	// SetStale only sends changes, so the events alternate as long as
+	// they are delivered in the order of the state changes. Each round
+	// stays below subscriptionBuffer, so that no events are coalesced.
+	const goroutines, changes = 4, 15
+
+	for round := 0; round < 100; round++ {
+		var wg sync.WaitGroup
+		for g := 0; g < goroutines; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < changes; i++ {
+					hub.SetStale("SM20", (i+g)%2 == 0)
+				}
+			}(g)
+		}
+		wg.Wait()
+		hub.Broadcast(Event{Name: UpdateStale, DeviceName: "end"})
+
+		select {
+		case <-done:
+		case <-time.After(time.Second * 5):
+			t.Fatal("timeout waiting for the events")
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i := 1; i < len(received); i++ {
+		if received[i] == received[i-1] {
+			t.Fatalf("event %d: stale %v delivered twice in a row", i, received[i])
+		}
+	}
+	if len(received) == 0 || received[len(received)-1] != hub.Stale("SM20") {
+		t.Fatalf("last event doesn't match the state of the hub (%v)", hub.Stale("SM20"))
+	}
+}
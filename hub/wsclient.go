@@ -0,0 +1,79 @@
+package hub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// writeTimeout avoids that a stale client blocks its sender forever.
+const writeTimeout = time.Second * 2
+
+// sendBuffer is the number of events which are queued for a client. A
+// client which falls further behind is disconnected.
+const sendBuffer = 64
+
+// WsClient is a wrapper for clients connected through a Websocket. The
+// events are queued on the send channel and written by the client's own
+// goroutine, so that a slow client doesn't block the hub.
+type WsClient struct {
+	*websocket.Conn
+	send chan Event // closed by the hub when the client is removed
+}
+
+func newWsClient(conn *websocket.Conn, queued int) *WsClient {
+	return &WsClient{
+		Conn: conn,
+		send: make(chan Event, queued+sendBuffer),
+	}
+}
+
+// listen on the websocket. Despite that no data is read, this function
+// is necessary to reply to incoming ping messages.
+func (c *WsClient) listen(closer chan<- *WsClient) {
+	defer func() {
+		closer <- c
+	}()
+
+	for {
+		// in case of an error just return and signal closing down of the ws
+		if _, _, err := c.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvents writes the queued events until the send channel is closed.
+// If a write fails, the connection is closed, which stops listen and
+// removes the client from the hub.
+func (c *WsClient) writeEvents() {
+	for ev := range c.send {
+		if err := c.write(ev); err != nil {
+			log.Printf("error writing to client %v: %v\n", c.RemoteAddr(), err)
+			c.Close()
+			// drain the queue until the hub has removed the client
+			for range c.send {
+			}
+			return
+		}
+	}
+}
+
+func (c *WsClient) write(event Event) error {
+
+	b, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("unable to serialize msg %v: %v", event, err)
+	}
+	if err := c.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+	if err := c.WriteMessage(websocket.TextMessage, b); err != nil {
+		return err
+	}
+
+	return nil
+}
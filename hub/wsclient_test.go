@@ -0,0 +1,95 @@
+package hub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialWs opens a websocket connection to the hub with the given Origin
+// header (none if empty).
+func dialWs(t *testing.T, hub *Hub, origin string) (*websocket.Conn, error) {
+	srv := httptest.NewServer(http.HandlerFunc(hub.wsHandler))
+	t.Cleanup(srv.Close)
+
+	header := http.Header{}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), header)
+	if err == nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, err
+}
+
+func wsClients(hub *Hub) int {
+	hub.RLock()
+	defer hub.RUnlock()
+	return len(hub.wsClients)
+}
+
+func TestCheckOrigin(t *testing.T) {
+
+	hub, err := NewHub()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub.SetAllowedOrigins([]string{"http://dashboard:8080"})
+
+	if _, err := dialWs(t, hub, ""); err != nil {
+		t.Fatalf("client without origin refused: %v", err)
+	}
+	if _, err := dialWs(t, hub, "http://dashboard:8080"); err != nil {
+		t.Fatalf("allowed origin refused: %v", err)
+	}
+	if _, err := dialWs(t, hub, "http://evil.example.com"); err == nil {
+		t.Fatal("foreign origin accepted")
+	}
+}
+
+func TestBroadcastSlowClient(t *testing.T) {
+
+	hub, err := NewHub()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the client never reads
+	if _, err := dialWs(t, hub, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second * 5)
+	for wsClients(hub) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("client not registered")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	// the broadcasts must not wait for the client
+	payload := strings.Repeat("x", 4096)
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10000; i++ {
+			hub.Broadcast(Event{Name: UpdateStale, DeviceName: payload})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Broadcast blocked by a slow client")
+	}
+
+	if n := wsClients(hub); n != 0 {
+		t.Fatalf("slow client not disconnected (%d clients)", n)
+	}
+}
@@ -0,0 +1,120 @@
+// Package rigctldtest provides a fake rigctld server for the tests of
+// the rigctld poller and of rig follow.
+package rigctldtest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Rig is a minimal rigctld server which answers the 't' (PTT) and
+// 'f' (frequency) commands.
+type Rig struct {
+	sync.Mutex
+	ln    net.Listener
+	ptt   int
+	freq  int
+	down  bool // refuse connections
+	conns []net.Conn
+}
+
+// New starts a server on a free local port. It is stopped when the
+// test has finished.
+func New(t *testing.T) *Rig {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Rig{
+		ln: ln,
+	}
+	go r.serve()
+
+	t.Cleanup(func() {
+		ln.Close()
+		r.SetDown(true)
+	})
+
+	return r
+}
+
+// Address returns the address of the server.
+func (r *Rig) Address() string {
+	return r.ln.Addr().String()
+}
+
+func (r *Rig) serve() {
+	for {
+		conn, err := r.ln.Accept()
+		if err != nil {
+			return
+		}
+		r.Lock()
+		if r.down {
+			r.Unlock()
+			conn.Close()
+			continue
+		}
+		r.conns = append(r.conns, conn)
+		r.Unlock()
+		go r.handle(conn)
+	}
+}
+
+func (r *Rig) handle(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return
+		}
+		r.Lock()
+		ptt, freq := r.ptt, r.freq
+		r.Unlock()
+
+		switch strings.TrimSpace(line) {
+		case "t":
+			fmt.Fprintf(conn, "%d\n", ptt)
+		case "f":
+			fmt.Fprintf(conn, "%d\n", freq)
+		default:
+			fmt.Fprintf(conn, "RPRT -1\n")
+		}
+	}
+}
+
+// Set sets the PTT state (0: RX, 1: TX) and the frequency (Hz) of the
+// radio.
+func (r *Rig) Set(ptt, freq int) {
+	r.Lock()
+	defer r.Unlock()
+	r.ptt = ptt
+	r.freq = freq
+}
+
+// Tune sets the frequency (Hz) of the radio.
+func (r *Rig) Tune(freq int) {
+	r.Lock()
+	defer r.Unlock()
+	r.freq = freq
+}
+
+// SetDown drops all connections and refuses new ones while down is true.
+func (r *Rig) SetDown(down bool) {
+	r.Lock()
+	defer r.Unlock()
+	r.down = down
+	if !down {
+		return
+	}
+	for _, c := range r.conns {
+		c.Close()
+	}
+	r.conns = nil
+}
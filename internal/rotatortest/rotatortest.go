@@ -0,0 +1,113 @@
+// Package rotatortest provides a fake rotator for the tests of the pages
+// and the hub.
+package rotatortest
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+// Fake is a rotator which doesn't move; it records the requested
+// azimuths and stops. It implements rotator.Rotator.
+type Fake struct {
+	sync.Mutex
+	name    string
+	cfg     rotator.Config
+	azimuth int
+	preset  int
+	sets    []int
+	stops   int
+	err     error // returned by SetAzimuth
+}
+
+// New returns a rotator with the given configuration.
+func New(name string, cfg rotator.Config) *Fake {
+	return &Fake{name: name, cfg: cfg}
+}
+
+var _ rotator.Rotator = (*Fake)(nil)
+
+func (r *Fake) Name() string              { return r.name }
+func (r *Fake) HasAzimuth() bool          { return r.cfg.HasAzimuth }
+func (r *Fake) HasElevation() bool        { return false }
+func (r *Fake) Elevation() int            { return 0 }
+func (r *Fake) ElPreset() int             { return 0 }
+func (r *Fake) SetElevation(el int) error { return errors.New("no elevation") }
+func (r *Fake) StopElevation() error      { return nil }
+func (r *Fake) Close()                    {}
+
+func (r *Fake) Azimuth() int {
+	r.Lock()
+	defer r.Unlock()
+	return r.azimuth
+}
+
+func (r *Fake) AzPreset() int {
+	r.Lock()
+	defer r.Unlock()
+	return r.preset
+}
+
+// SetAzimuth records az as preset, unless an error has been set with
+// SetErr.
+func (r *Fake) SetAzimuth(az int) error {
+	r.Lock()
+	defer r.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.preset = az
+	r.sets = append(r.sets, az)
+	return nil
+}
+
+func (r *Fake) StopAzimuth() error {
+	return r.Stop()
+}
+
+func (r *Fake) Stop() error {
+	r.Lock()
+	defer r.Unlock()
+	r.stops++
+	return nil
+}
+
+func (r *Fake) Serialize() rotator.Object {
+	r.Lock()
+	defer r.Unlock()
+	return rotator.Object{
+		Name:    r.name,
+		Heading: rotator.Heading{Azimuth: r.azimuth, AzPreset: r.preset},
+		Config:  r.cfg,
+	}
+}
+
+// SetHeading sets the current azimuth of the rotator.
+func (r *Fake) SetHeading(az int) {
+	r.Lock()
+	defer r.Unlock()
+	r.azimuth = az
+}
+
+// SetErr makes SetAzimuth fail with err; nil makes it succeed again.
+func (r *Fake) SetErr(err error) {
+	r.Lock()
+	defer r.Unlock()
+	r.err = err
+}
+
+// Sets returns the azimuths which have been requested so far.
+func (r *Fake) Sets() []int {
+	r.Lock()
+	defer r.Unlock()
+	return append([]int{}, r.sets...)
+}
+
+// Stops returns the number of Stop and StopAzimuth calls.
+func (r *Fake) Stops() int {
+	r.Lock()
+	defer r.Unlock()
+	return r.stops
+}
@@ -0,0 +1,120 @@
+// Package switchtest provides a fake switch for the tests of the pages
+// and the hub.
+package switchtest
+
+import (
+	"fmt"
+	"sync"
+
+	Switch "github.com/dh1tw/remoteSwitch/switch"
+)
+
+// Fake is a switch which keeps its ports in memory and records every
+// SetPort call. It implements Switch.Switcher.
+type Fake struct {
+	sync.Mutex
+	name  string
+	ports []Switch.Port
+	sets  int
+	err   error // returned by GetPort and SetPort
+}
+
+// New returns a switch with the given ports.
+func New(name string, ports ...Switch.Port) *Fake {
+	s := &Fake{name: name}
+	for _, p := range ports {
+		s.ports = append(s.ports, copyPort(p))
+	}
+	return s
+}
+
+func copyPort(p Switch.Port) Switch.Port {
+	p.Terminals = append([]Switch.Terminal{}, p.Terminals...)
+	return p
+}
+
+var _ Switch.Switcher = (*Fake)(nil)
+
+func (s *Fake) Name() string { return s.name }
+func (s *Fake) Close()       {}
+
+// SetErr makes GetPort and SetPort fail with err (nil: succeed), e.g.
+// to simulate a lost connection to the switch.
+func (s *Fake) SetErr(err error) {
+	s.Lock()
+	defer s.Unlock()
+	s.err = err
+}
+
+func (s *Fake) port(name string) (*Switch.Port, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	for i := range s.ports {
+		if s.ports[i].Name == name {
+			return &s.ports[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown port %s", name)
+}
+
+func (s *Fake) GetPort(name string) (Switch.Port, error) {
+	s.Lock()
+	defer s.Unlock()
+	p, err := s.port(name)
+	if err != nil {
+		return Switch.Port{}, err
+	}
+	return copyPort(*p), nil
+}
+
+// SetPort sets the states of the terminals of p. Unknown terminals are
+// ignored.
+func (s *Fake) SetPort(p Switch.Port) error {
+	s.Lock()
+	defer s.Unlock()
+	port, err := s.port(p.Name)
+	if err != nil {
+		return err
+	}
+	s.sets++
+	for _, t := range p.Terminals {
+		for i := range port.Terminals {
+			if port.Terminals[i].Name == t.Name {
+				port.Terminals[i].State = t.State
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Fake) Serialize() Switch.Device {
+	s.Lock()
+	defer s.Unlock()
+	d := Switch.Device{Name: s.name}
+	for _, p := range s.ports {
+		d.Ports = append(d.Ports, copyPort(p))
+	}
+	return d
+}
+
+// Sets returns the number of successful SetPort calls.
+func (s *Fake) Sets() int {
+	s.Lock()
+	defer s.Unlock()
+	return s.sets
+}
+
+// State returns the state of a terminal.
+func (s *Fake) State(port, terminal string) bool {
+	p, err := s.GetPort(port)
+	if err != nil {
+		return false
+	}
+	for _, t := range p.Terminals {
+		if t.Name == terminal {
+			return t.State
+		}
+	}
+	return false
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	Switch "github.com/dh1tw/remoteSwitch/switch"
+	esd "github.com/dh1tw/streamdeck"
+	"github.com/dh1tw/touchctl/config"
+	"github.com/dh1tw/touchctl/hub"
+	bandpage "github.com/dh1tw/touchctl/pages/band"
+	"github.com/dh1tw/touchctl/pages/bandswitch"
+	locatorpage "github.com/dh1tw/touchctl/pages/locator"
+	presetpage "github.com/dh1tw/touchctl/pages/preset"
+	rotatorpage "github.com/dh1tw/touchctl/pages/rotator"
+	stackpage "github.com/dh1tw/touchctl/pages/stackmatch"
+	"github.com/dh1tw/touchctl/rigfollow"
+	"github.com/dh1tw/touchctl/store"
+	"github.com/dh1tw/touchctl/tx"
+)
+
+// layout is the page tree built from the configuration.
+type layout struct {
+	root       esd.Page
+	stacks     map[string]*stackpage.StackPage // key: band name
+	bandswitch *bandswitch.BandswitchPage      // optional
+	hub        *hub.Hub
+	subs       []*hub.Subscription
+}
+
+// bandswitchKey is the name of the bandswitch page.
+const bandswitchKey = "bandswitch"
+
+// stackConfig converts the stack section of the configuration file
+// into the configuration of a stack page.
+func stackConfig(s config.Stack) stackpage.StackConfig {
+	sc := stackpage.StackConfig{
+		Band:      s.Band,
+		Name:      s.Switch,
+		MinActive: s.MinActiveTerminals(),
+		MaxActive: s.MaxActive,
+	}
+
+	for _, r := range s.Rotators {
+		sc.Rotators = append(sc.Rotators, stackpage.SmRotator{
+			Name:        r.Name,
+			ShortName:   r.ShortName,
+			Button:      r.Button,
+			LabelButton: r.LabelButton,
+			Compass:     r.Display == config.DisplayCompass,
+			Presets:     presets(r.Presets),
+		})
+	}
+
+	for _, t := range s.Terminals {
+		sc.Terminals = append(sc.Terminals, stackpage.SmTerminal{
+			Name:      t.Name,
+			ShortName: t.ShortName,
+			Index:     t.Index,
+			Button:    t.Button,
+		})
+	}
+
+	for _, c := range s.Combinations {
+		sc.Combinations = append(sc.Combinations, stackpage.SmCombination{
+			Name:      c.Name,
+			Button:    c.Button,
+			Terminals: c.Terminals,
+		})
+	}
+
+	return sc
+}
+
+// presets converts the presets of the configuration file into the
+// presets of the preset page.
+func presets(ps []config.Preset) []presetpage.Preset {
+	res := []presetpage.Preset{}
+	for _, p := range ps {
+		res = append(res, presetpage.Preset{
+			Name:    p.Name,
+			Azimuth: p.Azimuth,
+		})
+	}
+	return res
+}
+
+// rotatorConfig converts the jog, presets, qth, locators and prefixes
+// sections of the configuration file into the configuration of the
+// rotator pages. The presets of the prefixes point at the DXCC entities
+// and are added to the configured presets, including those of the
+// rotators which have their own presets.
+func rotatorConfig(cfg *config.Config) (rotatorpage.Config, error) {
+	rc := rotatorpage.Config{
+		Presets: presets(cfg.Presets),
+	}
+
+	if j := cfg.Jog; j != nil {
+		rc.Jog = rotatorpage.JogConfig{
+			Step:   j.Step,
+			Repeat: j.Repeat,
+		}
+	}
+
+	if cfg.QTH == nil {
+		return rc, nil
+	}
+
+	// the positions have already been validated
+	qth, _ := cfg.QTH.Point()
+	rc.Locator = &locatorpage.Config{
+		QTH: qth,
+	}
+	for _, l := range cfg.Locators {
+		p, _ := l.Point()
+		rc.Locator.Entries = append(rc.Locator.Entries, locatorpage.Entry{
+			Name:  l.Name,
+			Point: p,
+		})
+	}
+
+	if len(cfg.Prefixes) == 0 {
+		return rc, nil
+	}
+
+	t, err := cfg.CountryTable()
+	if err != nil {
+		return rc, err
+	}
+	for _, prefix := range cfg.Prefixes {
+		e, ok := t.Lookup(prefix)
+		if !ok {
+			return rc, fmt.Errorf("unknown prefix '%s'", prefix)
+		}
+		rc.Prefixes = append(rc.Prefixes, presetpage.Preset{
+			Name:    strings.ToUpper(prefix),
+			Azimuth: rc.Locator.Bearing(e.Point),
+		})
+	}
+
+	return rc, nil
+}
+
+// bandswitchConfig converts the bandswitch section of the configuration
+// file into the configuration of a bandswitch page.
+func bandswitchConfig(bs *config.Bandswitch) bandswitch.BandswitchConfig {
+	bc := bandswitch.BandswitchConfig{
+		Name:  bs.Switch,
+		Label: bs.ShortName,
+	}
+
+	for _, t := range bs.Terminals {
+		bc.Terminals = append(bc.Terminals, bandswitch.BsTerminal{
+			Name:      t.Name,
+			ShortName: t.ShortName,
+			Button:    t.Button,
+		})
+	}
+
+	return bc
+}
+
+// buildLayout creates the band page and all stack pages described
+// in the configuration. The presets saved on the Stream Deck are kept
+// in st.
+func buildLayout(sd *esd.StreamDeck, h *hub.Hub, il *tx.Interlock, f *rigfollow.Follower, st *store.Store, cfg *config.Config) (*layout, error) {
+
+	l := &layout{
+		stacks: make(map[string]*stackpage.StackPage),
+		hub:    h,
+	}
+
+	stacks := make(map[string]esd.Page)
+
+	rc, err := rotatorConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	rc.Store = st
+
+	for _, s := range cfg.Stacks {
+		sc := stackConfig(s)
+		sc.Rotator = rc
+		sp, err := stackpage.NewStackPage(sd, nil, h, il, sc)
+		if err != nil {
+			return nil, fmt.Errorf("band %s: %v", s.Band, err)
+		}
+		l.stacks[s.Band] = sp
+		stacks[s.Band] = sp
+	}
+
+	bands := make(map[int]bandpage.Band)
+	for _, b := range cfg.Bands {
+		bands[b.Button] = bandpage.Band{
+			Name:      b.Name,
+			ShortName: b.ShortName,
+		}
+	}
+
+	var opts []bandpage.Option
+
+	// the bandswitch is opened through its own button on the band page
+	if cfg.Bandswitch != nil {
+		bsp, err := bandswitch.NewBandswitchPage(sd, nil, h, il, bandswitchConfig(cfg.Bandswitch))
+		if err != nil {
+			return nil, fmt.Errorf("bandswitch: %v", err)
+		}
+		l.bandswitch = bsp
+		opts = append(opts, bandpage.PageButton(cfg.Bandswitch.Button, cfg.Bandswitch.ShortName, bsp))
+	}
+
+	if cfg.RigFollow != nil && f != nil {
+		opts = append(opts, bandpage.ToggleButton(cfg.RigFollow.Button, cfg.RigFollow.Text, f))
+	}
+
+	l.root = bandpage.NewBandPage(sd, nil, bands, stacks, opts...)
+	for _, sp := range l.stacks {
+		sp.SetParent(l.root)
+	}
+	if l.bandswitch != nil {
+		l.bandswitch.SetParent(l.root)
+	}
+
+	return l, nil
+}
+
+// followBands returns the frequency ranges of the configured bands.
+func followBands(cfg *config.Config) []rigfollow.Band {
+	bands := []rigfollow.Band{}
+	for _, b := range cfg.Bands {
+		if b.MaxFreq > 0 {
+			bands = append(bands, rigfollow.Band{
+				Name: b.Name,
+				Min:  b.MinFreq * 1000,
+				Max:  b.MaxFreq * 1000,
+			})
+			continue
+		}
+		if db, ok := rigfollow.DefaultBand(b.Name); ok {
+			bands = append(bands, db)
+		}
+	}
+	return bands
+}
+
+// register subscribes all pages to the events of the hub. Since devices
+// might have been added or removed since the pages were built, the pages
+// are refreshed once subscribed.
+func (l *layout) register() {
+	for _, sp := range l.stacks {
+		l.subs = append(l.subs, l.hub.Subscribe(sp.EventHandler))
+		sp.Refresh()
+	}
+	if l.bandswitch != nil {
+		l.subs = append(l.subs, l.hub.Subscribe(l.bandswitch.EventHandler,
+			hub.AddSwitch, hub.RemoveSwitch, hub.UpdateSwitch, hub.UpdateStale))
+		l.bandswitch.Refresh()
+	}
+	l.hub.SetPortGuard(l.checkPort)
+}
+
+// unregister cancels the subscriptions of all pages.
+func (l *layout) unregister() {
+	l.hub.SetPortGuard(nil)
+	for _, s := range l.subs {
+		l.hub.Unsubscribe(s)
+	}
+	l.subs = nil
+}
+
+// checkPort applies the policies of the stack pages and the bandswitch
+// page to the port requests of the HTTP API.
+func (l *layout) checkPort(s Switch.Switcher, p Switch.Port) error {
+	for _, sp := range l.stacks {
+		if err := sp.CheckPort(s, p); err != nil {
+			return err
+		}
+	}
+	if l.bandswitch != nil {
+		return l.bandswitch.CheckPort(s, p)
+	}
+	return nil
+}
+
+// page returns the stack page of a band or the bandswitch page.
+func (l *layout) page(name string) (esd.Page, bool) {
+	if name == bandswitchKey {
+		return l.bandswitch, l.bandswitch != nil
+	}
+	sp, ok := l.stacks[name]
+	return sp, ok
+}
+
+// pageOf returns the name of the stack or bandswitch page p belongs to.
+// Sub pages (e.g. the rotator page) are resolved through their parents.
+func (l *layout) pageOf(p esd.Page) (string, bool) {
+	for p != nil && p != l.root {
+		if l.bandswitch != nil && p == esd.Page(l.bandswitch) {
+			return bandswitchKey, true
+		}
+		for band, sp := range l.stacks {
+			if p == esd.Page(sp) {
+				return band, true
+			}
+		}
+		p = p.Parent()
+	}
+	return "", false
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	Switch "github.com/dh1tw/remoteSwitch/switch"
+	esd "github.com/dh1tw/streamdeck"
+	"github.com/dh1tw/touchctl/config"
+	"github.com/dh1tw/touchctl/hub"
+	"github.com/dh1tw/touchctl/internal/switchtest"
+	"github.com/dh1tw/touchctl/tx"
+)
+
+const testConfig = `
+bands:
+  - {name: 20m, short_name: " 20m", button: 5}
+  - {name: 40m, short_name: " 40m", button: 12}
+
+stacks:
+  - band: 20m
+    switch: Stackmatch 20m
+    max_active: 2
+    terminals:
+      - {name: OB11-TWR1, short_name: OB11, index: 0, button: 13}
+      - {name: OB11-TWR2, short_name: OB11, index: 1, button: 12}
+      - {name: OB11-TWR3, short_name: OB11, index: 2, button: 11}
+
+bandswitch:
+  switch: Bandswitch
+  short_name: " BS "
+  button: 14
+  terminals:
+    - {name: 20m, short_name: " 20m", button: 5}
+    - {name: 40m, short_name: " 40m", button: 12}
+`
+
+// TestHTTPPolicy checks that the REST API applies the same policies as
+// the pages of the layout.
+func TestHTTPPolicy(t *testing.T) {
+
+	cfg, err := config.Parse("touchctl.yaml", []byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := hub.NewHub()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sm := switchtest.New("Stackmatch 20m", Switch.Port{
+		Name: "SM",
+		Terminals: []Switch.Terminal{
+			{Name: "OB11-TWR1", State: true},
+			{Name: "OB11-TWR2"},
+			{Name: "OB11-TWR3"},
+		},
+	})
+	bs := switchtest.New("Bandswitch",
+		Switch.Port{Name: "A", Terminals: []Switch.Terminal{{Name: "20m", State: true}, {Name: "40m"}}},
+		Switch.Port{Name: "B", Terminals: []Switch.Terminal{{Name: "20m"}, {Name: "40m", State: true}}},
+	)
+	other := switchtest.New("Dummy", Switch.Port{Name: "SM", Terminals: []Switch.Terminal{{Name: "a", State: true}}})
+	for _, s := range []Switch.Switcher{sm, bs, other} {
+		if err := h.AddSwitch(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the pages are never drawn, so no Stream Deck is needed
+	l, err := buildLayout(&esd.StreamDeck{}, h, tx.NewInterlock(), nil, nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.register()
+	defer l.unregister()
+
+	handler := h.Handler()
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+	}{
+		{"stackmatch: switch off last terminal", "/api/v1.0/switch/Stackmatch 20m/port/SM",
+			`{"terminals":[{"name":"OB11-TWR1"}]}`, http.StatusConflict},
+		{"stackmatch: switch on", "/api/v1.0/switch/Stackmatch 20m/port/SM",
+			`{"terminals":[{"name":"OB11-TWR2","state":true}]}`, http.StatusOK},
+		{"stackmatch: exceed max_active", "/api/v1.0/switch/Stackmatch 20m/port/SM",
+			`{"terminals":[{"name":"OB11-TWR3","state":true}]}`, http.StatusConflict},
+		{"stackmatch: unknown terminal", "/api/v1.0/switch/Stackmatch 20m/port/SM",
+			`{"terminals":[{"name":"4L","state":true}]}`, http.StatusInternalServerError},
+		{"bandswitch: antenna of the other port", "/api/v1.0/switch/Bandswitch/port/A",
+			`{"terminals":[{"name":"40m","state":true}]}`, http.StatusConflict},
+		{"bandswitch: free antenna", "/api/v1.0/switch/Bandswitch/port/B",
+			`{"terminals":[{"name":"40m"}]}`, http.StatusOK},
+		{"bandswitch: antenna freed", "/api/v1.0/switch/Bandswitch/port/A",
+			`{"terminals":[{"name":"40m","state":true}]}`, http.StatusOK},
+		{"switch without policy", "/api/v1.0/switch/Dummy/port/SM",
+			`{"terminals":[{"name":"a"}]}`, http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", strings.Replace(tc.path, " ", "%20", -1), strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tc.status {
+				t.Fatalf("got status %d, want %d (%s)", rec.Code, tc.status, rec.Body.String())
+			}
+		})
+	}
+
+	if sm.Sets() != 1 || bs.Sets() != 2 {
+		t.Fatalf("got %d stackmatch and %d bandswitch changes, want 1 and 2", sm.Sets(), bs.Sets())
+	}
+
+	// without a layout, no policy is applied
+	l.unregister()
+	req := httptest.NewRequest("PUT", "/api/v1.0/switch/Stackmatch%2020m/port/SM", strings.NewReader(`{"terminals":[{"name":"OB11-TWR1"},{"name":"OB11-TWR2"}]}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d without a layout, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestRotatorConfigPrefixes(t *testing.T) {
+
+	cfg, err := config.Parse("test.yaml", []byte(`
+bands:
+  - {name: 20m, short_name: " 20m", button: 5}
+qth:
+  locator: JO62qm
+presets:
+  - {name: N, azimuth: 0}
+prefixes: [ja, VK]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := rotatorConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the prefixes are kept apart, so that they are also added to the
+	// presets of the rotators which replace the station presets
+	if len(rc.Presets) != 1 || rc.Presets[0].Name != "N" {
+		t.Fatalf("got presets %v, want [N]", rc.Presets)
+	}
+	if len(rc.Prefixes) != 2 || rc.Prefixes[0].Name != "JA" || rc.Prefixes[1].Name != "VK" {
+		t.Fatalf("got prefixes %v, want [JA VK]", rc.Prefixes)
+	}
+}
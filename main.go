@@ -6,7 +6,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
-	"sync"
+	"strings"
 	"time"
 
 	natsBroker "github.com/asim/go-micro/plugins/broker/nats/v3"
@@ -16,12 +16,14 @@ import (
 	"github.com/asim/go-micro/v3/client"
 	"github.com/asim/go-micro/v3/registry"
 	"github.com/asim/go-micro/v3/transport"
-	"github.com/dh1tw/remoteRotator/rotator"
-	sw "github.com/dh1tw/remoteSwitch/switch"
 	esd "github.com/dh1tw/streamdeck"
+	"github.com/dh1tw/touchctl/config"
 	"github.com/dh1tw/touchctl/hub"
-	bandpage "github.com/dh1tw/touchctl/pages/band"
-	stackpage "github.com/dh1tw/touchctl/pages/stackmatch"
+	"github.com/dh1tw/touchctl/n1mm"
+	"github.com/dh1tw/touchctl/rigctld"
+	"github.com/dh1tw/touchctl/rigfollow"
+	"github.com/dh1tw/touchctl/store"
+	"github.com/dh1tw/touchctl/tx"
 	nats "github.com/nats-io/nats.go"
 	// profiling
 	// _ "net/http/pprof"
@@ -34,6 +36,17 @@ func main() {
 	urlFlag := flag.String("address", "localhost", "address of nats broker")
 	usernameFlag := flag.String("username", "", "nats username")
 	passwordFlag := flag.String("password", "", "nats password")
+	configFlag := flag.String("config", "touchctl.yaml", "path to the layout configuration file")
+	rigctldFlag := flag.String("rigctld", "", "address (host:port) of rigctld for the TX interlock and rig follow")
+	n1mmFlag := flag.String("n1mm", "", "udp address (e.g. :12060) for N1MM+/DXLog radio info broadcasts")
+	n1mmStationFlag := flag.String("n1mm-station", "", "N1MM+/DXLog station (computer) name whose radios are used (empty: all stations)")
+	n1mmRadioFlag := flag.Int("n1mm-radio", 0, "N1MM+/DXLog radio number to follow (0: radio with focus)")
+	txSubjectFlag := flag.String("tx-subject", "", "nats subject publishing the TX state for the TX interlock")
+	httpHostFlag := flag.String("http-host", "0.0.0.0", "host (network adapter) for the HTTP / websocket server")
+	httpPortFlag := flag.Int("http-port", 0, "port for the HTTP / websocket server (0: disabled)")
+	httpOriginsFlag := flag.String("http-origins", "", "comma separated list of additional origins (e.g. http://dashboard:8080) allowed to open a websocket")
+	stateFlag := flag.String("state", "touchctl-state.json", "path to the state file for the presets saved on the Stream Deck")
+	headingWindowFlag := flag.Duration("heading-window", time.Millisecond*200, "minimum interval between two heading updates of a rotator (0: disabled)")
 
 	flag.Parse()
 
@@ -58,6 +71,16 @@ func main() {
 		log.Fatal("missing nats password")
 	}
 
+	cfg, err := config.Load(*configFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	st, err := store.Open(*stateFlag)
+	if err != nil {
+		log.Fatalf("state file %s: %v", *stateFlag, err)
+	}
+
 	connClosed := make(chan struct{})
 
 	nopts := nats.GetDefaultOptions()
@@ -107,6 +130,7 @@ func main() {
 
 	cache := &serviceCache{
 		ttl:   time.Second * 20,
+		evict: time.Minute,
 		cache: make(map[string]time.Time),
 	}
 
@@ -116,6 +140,16 @@ func main() {
 		os.Exit(1)
 	}
 
+	h.SetHeadingWindow(*headingWindowFlag)
+	if len(*httpOriginsFlag) > 0 {
+		h.SetAllowedOrigins(strings.Split(*httpOriginsFlag, ","))
+	}
+
+	httpErrCh := make(chan struct{})
+	if *httpPortFlag > 0 {
+		go h.ListenHTTP(*httpHostFlag, *httpPortFlag, httpErrCh)
+	}
+
 	w := webserver{h, cl, cache}
 
 	// at startup, query the registry and add all found rotators and switches
@@ -126,6 +160,9 @@ func main() {
 	// watch the registry in a seperate thread for changes
 	go w.watchRegistry()
 
+	// mark rotators and switches as stale which disappeared from the registry
+	go w.watchLiveness()
+
 	// Channel to handle OS signals
 	osSignals := make(chan os.Signal, 1)
 
@@ -140,103 +177,92 @@ func main() {
 
 	defer sd.ClearAllBtns()
 
-	smConfig10m := stackpage.StackConfig{
-		Band: "10m",
-		Name: "Stackmatch 10m",
-		Ant1: stackpage.SmTerminal{Name: "OB11-TWR1", ShortName: "OB11", Index: 0},
-		Ant2: stackpage.SmTerminal{Name: "OB11-TWR2", ShortName: "OB11", Index: 1},
-		Ant3: stackpage.SmTerminal{Name: "OB11-TWR3", ShortName: "OB11", Index: 2},
-	}
-
-	smConfig15m := stackpage.StackConfig{
-		Band: "15m",
-		Name: "Stackmatch 15m",
-		Ant1: stackpage.SmTerminal{Name: "OB11-TWR1", ShortName: "OB11", Index: 0},
-		Ant3: stackpage.SmTerminal{Name: "OB11-TWR3", ShortName: "OB11", Index: 2},
-		Ant4: stackpage.SmTerminal{Name: "4L-TWR4", ShortName: " 4L ", Index: 3},
-	}
-
-	smConfig20m := stackpage.StackConfig{
-		Band: "20m",
-		Name: "Stackmatch 20m",
-		Ant1: stackpage.SmTerminal{Name: "OB11-TWR1", ShortName: "OB11", Index: 0},
-		Ant2: stackpage.SmTerminal{Name: "OB11-TWR2", ShortName: "OB11", Index: 1},
-		Ant3: stackpage.SmTerminal{Name: "OB11-TWR3", ShortName: "OB11", Index: 2},
-	}
-
-	smConfig40m := stackpage.StackConfig{
-		Band: "40m",
-		Name: "Stackmatch 40m",
-		Ant1: stackpage.SmTerminal{Name: "2L-TWR1", ShortName: " 2L ", Index: 0},
-		Ant3: stackpage.SmTerminal{Name: "DIPOL-TWR3", ShortName: "DIPL", Index: 2},
-	}
-
-	p10m := stackpage.NewStackPage(sd, nil, h, smConfig10m)
-	p15m := stackpage.NewStackPage(sd, nil, h, smConfig15m)
-	p20m := stackpage.NewStackPage(sd, nil, h, smConfig20m)
-	p40m := stackpage.NewStackPage(sd, nil, h, smConfig40m)
-
-	rotatorEvents["p10m"] = p10m.RotatorUpdateHandler
-	rotatorEvents["p15m"] = p15m.RotatorUpdateHandler
-	rotatorEvents["p20m"] = p20m.RotatorUpdateHandler
-	rotatorEvents["p40m"] = p40m.RotatorUpdateHandler
-
-	switchEvents["p10m"] = p10m.SwitchUpdateHandler
-	switchEvents["p15m"] = p15m.SwitchUpdateHandler
-	switchEvents["p20m"] = p20m.SwitchUpdateHandler
-	switchEvents["p40m"] = p40m.SwitchUpdateHandler
-
-	stacks := map[string]esd.Page{
-		"10m": p10m,
-		"15m": p15m,
-		"20m": p20m,
-		"40m": p40m,
-	}
-
-	currentPage := bandpage.NewBandPage(sd, nil, stacks)
-	p10m.SetParent(currentPage)
-	p15m.SetParent(currentPage)
-	p20m.SetParent(currentPage)
-	p40m.SetParent(currentPage)
-
-	var pMutex sync.Mutex
-	currentPage.SetActive(true)
-	currentPage.Draw()
-
-	cb := func(keyIndex int, state esd.BtnState) {
-		pMutex.Lock()
-		defer pMutex.Unlock()
-		newPage := currentPage.Set(keyIndex, state)
-		if newPage != nil {
-			currentPage.SetActive(false)
-			sd.ClearAllBtns()
-			currentPage = newPage
-			currentPage.SetActive(true)
-			currentPage.Draw()
-		}
+	il := tx.NewInterlock()
+	h.SetInterlock(il)
+	follower := rigfollow.NewFollower(followBands(cfg), cfg.RigFollow != nil && cfg.RigFollow.Enabled)
+
+	if len(*rigctldFlag) > 0 {
+		p := rigctld.NewPoller(
+			rigctld.Address(*rigctldFlag),
+			rigctld.PTTHandler(func(ptt bool) {
+				il.Set("rigctld:"+*rigctldFlag, ptt)
+			}),
+			rigctld.FrequencyHandler(follower.SetFrequency),
+		)
+		go p.Run()
+		defer p.Close()
 	}
 
-	sd.SetBtnEventCb(cb)
-
-	select {
-	case <-osSignals:
-		return
+	if len(*n1mmFlag) > 0 {
+		if len(*n1mmStationFlag) == 0 {
+			log.Println("n1mm: no station configured (-n1mm-station); the radios of all stations on the network lock the TX interlock")
+		}
+		l, err := n1mm.NewListener(
+			n1mm.Address(*n1mmFlag),
+			n1mm.Station(*n1mmStationFlag),
+			n1mm.RadioNr(*n1mmRadioFlag),
+			n1mm.EventHandler(func(r n1mm.Radio) {
+				il.Set("n1mm:"+r.ID(), r.Transmitting)
+				if *n1mmRadioFlag != 0 || r.Focus {
+					follower.SetFrequency(r.Frequency)
+				}
+			}),
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go l.Run()
+		defer l.Close()
 	}
-}
 
-var rotatorEvents map[string]func(r rotator.Rotator, status rotator.Heading) = map[string]func(r rotator.Rotator, status rotator.Heading){}
-var switchEvents map[string]func(s sw.Switcher, device sw.Device) = map[string]func(s sw.Switcher, device sw.Device){}
+	if len(*txSubjectFlag) > 0 {
+		txNatsOpts := nopts
+		txNatsOpts.Name = "touchCtl.client:tx"
+		nc, err := txNatsOpts.Connect()
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer nc.Close()
+		if _, err := il.SubscribeNats(nc, *txSubjectFlag); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-var rotatorEvent = func(r rotator.Rotator, status rotator.Heading) {
-	// fmt.Printf("rotor event: %v %v°\n", r.Name(), r.Azimuth())
-	for _, handler := range rotatorEvents {
-		go handler(r, status)
+	lay, err := buildLayout(sd, h, il, follower, st, cfg)
+	if err != nil {
+		log.Fatal(err)
 	}
-}
 
-var switchEvent = func(s sw.Switcher, device sw.Device) {
-	// fmt.Println("switch event: ", device)
-	for _, handler := range switchEvents {
-		go handler(s, device)
+	d := newDeck(sd, lay)
+	sd.SetBtnEventCb(d.btnEvent)
+	il.SetEventHandler(func(locked bool) {
+		d.redraw()
+	})
+	follower.SetEventHandler(d.showPage)
+
+	go watchConfig(*configFlag, func() {
+		cfg, err := config.Load(*configFlag)
+		if err != nil {
+			log.Printf("config not reloaded: %v", err)
+			return
+		}
+		lay, err := buildLayout(sd, h, il, follower, st, cfg)
+		if err != nil {
+			log.Printf("config not reloaded: %v", err)
+			return
+		}
+		follower.SetBands(followBands(cfg))
+		if cfg.RigFollow == nil {
+			follower.SetEnabled(false)
+		}
+		d.replaceLayout(lay)
+		log.Printf("reloaded %s", *configFlag)
+	})
+
+	select {
+	case <-osSignals:
+		return
+	case <-httpErrCh:
+		return
 	}
 }
@@ -0,0 +1,209 @@
+// Package n1mm listens for the <RadioInfo> UDP broadcasts of contest
+// loggers like N1MM+ and DXLog and keeps track of the frequency and the
+// transmit state of each radio.
+package n1mm
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+)
+
+// DefaultAddress is the default address on which N1MM+ broadcasts
+// the radio information.
+const DefaultAddress = ":12060"
+
+// radioInfo is the <RadioInfo> packet. Frequencies are in 10 Hz.
+type radioInfo struct {
+	XMLName        xml.Name `xml:"RadioInfo"`
+	App            string   `xml:"app"`
+	StationName    string   `xml:"StationName"`
+	RadioNr        int      `xml:"RadioNr"`
+	Freq           int      `xml:"Freq"`
+	TXFreq         int      `xml:"TXFreq"`
+	Mode           string   `xml:"Mode"`
+	FocusRadioNr   int      `xml:"FocusRadioNr"`
+	ActiveRadioNr  int      `xml:"ActiveRadioNr"`
+	IsTransmitting bool     `xml:"IsTransmitting"`
+	RadioName      string   `xml:"RadioName"`
+}
+
+// Radio is the state of a radio as reported by the logger.
+type Radio struct {
+	Station      string
+	Nr           int
+	Name         string
+	Frequency    int // Hz
+	TXFrequency  int // Hz
+	Mode         string
+	Transmitting bool
+	Focus        bool // the radio has the keyboard focus
+}
+
+// ID returns a unique identifier of the radio.
+func (r Radio) ID() string {
+	return fmt.Sprintf("%s/%d", r.Station, r.Nr)
+}
+
+// Parse decodes a <RadioInfo> packet.
+func Parse(data []byte) (Radio, error) {
+	ri := radioInfo{}
+	if err := xml.Unmarshal(data, &ri); err != nil {
+		return Radio{}, err
+	}
+
+	r := Radio{
+		Station:      strings.TrimSpace(ri.StationName),
+		Nr:           ri.RadioNr,
+		Name:         ri.RadioName,
+		Frequency:    ri.Freq * 10,
+		TXFrequency:  ri.TXFreq * 10,
+		Mode:         ri.Mode,
+		Transmitting: ri.IsTransmitting,
+		Focus:        ri.FocusRadioNr == ri.RadioNr,
+	}
+
+	return r, nil
+}
+
+// Listener receives the UDP broadcasts of the logger. Since every logger
+// on the network broadcasts its radios, the Listener can be limited to
+// the radios of one station (and to a single radio of that station).
+type Listener struct {
+	sync.RWMutex
+	address      string
+	station      string
+	radioNr      int
+	conn         net.PacketConn
+	radios       map[string]Radio // key: Radio.ID()
+	eventHandler func(Radio)
+}
+
+// NewListener opens the UDP socket. Functional options can be supplied
+// to modify the default behaviour. Call Run to start receiving.
+func NewListener(opts ...func(*Listener)) (*Listener, error) {
+	l := &Listener{
+		address: DefaultAddress,
+		radios:  make(map[string]Radio),
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	conn, err := net.ListenPacket("udp", l.address)
+	if err != nil {
+		return nil, err
+	}
+	l.conn = conn
+
+	return l, nil
+}
+
+// Address is a functional option to set the local UDP address
+// (e.g. ":12060").
+func Address(address string) func(*Listener) {
+	return func(l *Listener) {
+		l.address = address
+	}
+}
+
+// Station is a functional option which limits the Listener to the radios
+// of the station with the given name (the computer name of the logger,
+// not case sensitive). By default the radios of all stations are accepted.
+func Station(name string) func(*Listener) {
+	return func(l *Listener) {
+		l.station = strings.TrimSpace(name)
+	}
+}
+
+// RadioNr is a functional option which limits the Listener to the radio
+// with the given number (e.g. 1 or 2 in SO2R). By default (0) all radios
+// are accepted.
+func RadioNr(nr int) func(*Listener) {
+	return func(l *Listener) {
+		l.radioNr = nr
+	}
+}
+
+// EventHandler is a functional option which sets the handler which gets
+// called for every received radio update.
+func EventHandler(h func(Radio)) func(*Listener) {
+	return func(l *Listener) {
+		l.eventHandler = h
+	}
+}
+
+// Addr returns the local address of the UDP socket.
+func (l *Listener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+// Close closes the UDP socket, which stops Run.
+func (l *Listener) Close() error {
+	return l.conn.Close()
+}
+
+// accept returns true if the radio belongs to the configured station
+// and radio.
+func (l *Listener) accept(r Radio) bool {
+	if l.station != "" && !strings.EqualFold(l.station, r.Station) {
+		return false
+	}
+	if l.radioNr != 0 && l.radioNr != r.Nr {
+		return false
+	}
+	return true
+}
+
+// Radios returns the last known state of all radios.
+func (l *Listener) Radios() []Radio {
+	l.RLock()
+	defer l.RUnlock()
+
+	radios := make([]Radio, 0, len(l.radios))
+	for _, r := range l.radios {
+		radios = append(radios, r)
+	}
+	return radios
+}
+
+// Run is a blocking function which receives and processes packets
+// until the Listener is closed.
+func (l *Listener) Run() {
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			return // socket closed
+		}
+
+		// loggers broadcast other packets (contact info, spots, ...)
+		// on the same port
+		if !strings.Contains(string(buf[:n]), "<RadioInfo>") {
+			continue
+		}
+
+		r, err := Parse(buf[:n])
+		if err != nil {
+			log.Printf("n1mm: %v\n", err)
+			continue
+		}
+
+		if !l.accept(r) {
+			continue
+		}
+
+		l.Lock()
+		l.radios[r.ID()] = r
+		h := l.eventHandler
+		l.Unlock()
+
+		if h != nil {
+			h(r)
+		}
+	}
+}
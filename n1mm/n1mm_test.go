@@ -0,0 +1,171 @@
+package n1mm_test
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dh1tw/touchctl/n1mm"
+	"github.com/dh1tw/touchctl/tx"
+)
+
+func radioInfo(station string, nr, freq int, tx bool, focus int) []byte {
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<RadioInfo>
+	<app>N1MM</app>
+	<StationName>%s</StationName>
+	<RadioNr>%d</RadioNr>
+	<Freq>%d</Freq>
+	<TXFreq>%d</TXFreq>
+	<Mode>CW</Mode>
+	<FocusRadioNr>%d</FocusRadioNr>
+	<ActiveRadioNr>%d</ActiveRadioNr>
+	<IsTransmitting>%v</IsTransmitting>
+	<RadioName>K3</RadioName>
+</RadioInfo>`, station, nr, freq/10, freq/10, focus, focus, tx))
+}
+
+// listen starts a Listener on a loopback port and returns the connection
+// to send packets to it and the channel receiving its events. The
+// handler h (if not nil) is called for every event before it is sent
+// to the channel.
+func listen(t *testing.T, h func(n1mm.Radio), opts ...func(*n1mm.Listener)) (*n1mm.Listener, net.Conn, chan n1mm.Radio) {
+	events := make(chan n1mm.Radio, 10)
+	opts = append([]func(*n1mm.Listener){
+		n1mm.Address("127.0.0.1:0"),
+		n1mm.EventHandler(func(r n1mm.Radio) {
+			if h != nil {
+				h(r)
+			}
+			events <- r
+		}),
+	}, opts...)
+
+	l, err := n1mm.NewListener(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go l.Run()
+	t.Cleanup(func() { l.Close() })
+
+	conn, err := net.Dial("udp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return l, conn, events
+}
+
+func send(t *testing.T, conn net.Conn, data []byte) {
+	t.Helper()
+	if _, err := conn.Write(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectRadio(t *testing.T, ch chan n1mm.Radio) n1mm.Radio {
+	t.Helper()
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(time.Second * 2):
+		t.Fatal("timeout waiting for radio info")
+	}
+	return n1mm.Radio{}
+}
+
+func expectNoRadio(t *testing.T, ch chan n1mm.Radio) {
+	t.Helper()
+	select {
+	case r := <-ch:
+		t.Fatalf("unexpected radio info of %s", r.ID())
+	case <-time.After(time.Millisecond * 100):
+	}
+}
+
+func expectLock(t *testing.T, ch chan bool, want bool) {
+	t.Helper()
+	select {
+	case locked := <-ch:
+		if locked != want {
+			t.Fatalf("got TX lock %v, want %v", locked, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timeout waiting for TX lock %v", want)
+	}
+}
+
+func TestListener(t *testing.T) {
+
+	il := tx.NewInterlock()
+	lockCh := make(chan bool, 10)
+	il.SetEventHandler(func(locked bool) {
+		lockCh <- locked
+	})
+
+	// the interlock is driven like in main
+	l, conn, events := listen(t, func(r n1mm.Radio) {
+		il.Set("n1mm:"+r.ID(), r.Transmitting)
+	})
+
+	send(t, conn, radioInfo("RUN", 1, 14025000, false, 1))
+	r := expectRadio(t, events)
+	if r.ID() != "RUN/1" || r.Frequency != 14025000 || r.Transmitting || !r.Focus {
+		t.Fatalf("unexpected radio %+v", r)
+	}
+
+	send(t, conn, radioInfo("RUN", 2, 7010000, true, 1))
+	r = expectRadio(t, events)
+	if r.ID() != "RUN/2" || r.Frequency != 7010000 || !r.Transmitting || r.Focus {
+		t.Fatalf("unexpected radio %+v", r)
+	}
+
+	expectLock(t, lockCh, true)
+
+	// other packets on the same port are ignored
+	send(t, conn, []byte("<contactinfo><call>DL0XX</call></contactinfo>"))
+	expectNoRadio(t, events)
+
+	radios := map[string]n1mm.Radio{}
+	for _, r := range l.Radios() {
+		radios[r.ID()] = r
+	}
+	if len(radios) != 2 {
+		t.Fatalf("got %d radios, want 2", len(radios))
+	}
+	if radios["RUN/1"].Transmitting || !radios["RUN/2"].Transmitting {
+		t.Fatalf("unexpected TX states %+v", radios)
+	}
+	if radios["RUN/2"].Frequency != 7010000 {
+		t.Fatalf("got %d Hz, want 7010000 Hz", radios["RUN/2"].Frequency)
+	}
+
+	send(t, conn, radioInfo("RUN", 2, 7010000, false, 1))
+	expectRadio(t, events)
+	expectLock(t, lockCh, false)
+}
+
+func TestListenerFilter(t *testing.T) {
+
+	l, conn, events := listen(t, nil, n1mm.Station("run"), n1mm.RadioNr(2))
+
+	// another station on the network
+	send(t, conn, radioInfo("MULT", 2, 21010000, true, 2))
+	expectNoRadio(t, events)
+
+	// another radio of the station
+	send(t, conn, radioInfo("RUN", 1, 14025000, true, 1))
+	expectNoRadio(t, events)
+
+	send(t, conn, radioInfo("RUN", 2, 7010000, true, 1))
+	r := expectRadio(t, events)
+	if r.ID() != "RUN/2" || !r.Transmitting {
+		t.Fatalf("unexpected radio %+v", r)
+	}
+
+	if radios := l.Radios(); len(radios) != 1 {
+		t.Fatalf("got %d radios, want 1", len(radios))
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	esd "github.com/dh1tw/streamdeck"
 	"github.com/dh1tw/streamdeck-buttons/label"
+	ledBtn "github.com/dh1tw/streamdeck-buttons/ledbutton"
 )
 
 type bandPage struct {
@@ -16,6 +17,53 @@ type bandPage struct {
 	active    bool
 	labels    map[int]*bandButton
 	stacks    map[string]esd.Page
+	toggles   map[int]*toggleButton
+	pages     map[int]*pageButton
+}
+
+type toggleButton struct {
+	text   string
+	target Switchable
+	btn    *ledBtn.LedButton
+}
+
+// Switchable is a subsystem (e.g. rig follow) which can be enabled and
+// disabled through a button on the band page.
+type Switchable interface {
+	Enabled() bool
+	SetEnabled(bool)
+}
+
+// Option is a functional option of the band page.
+type Option func(*bandPage)
+
+// ToggleButton is a functional option which adds a button with a LED that
+// enables / disables s.
+func ToggleButton(btnIndex int, text string, s Switchable) Option {
+	return func(bp *bandPage) {
+		bp.toggles[btnIndex] = &toggleButton{
+			text:   text,
+			target: s,
+		}
+	}
+}
+
+type pageButton struct {
+	text  string
+	page  esd.Page
+	label *label.Label
+}
+
+// PageButton is a functional option which adds a button that opens
+// page (e.g. the bandswitch). It is independent of the band buttons, so
+// a band with the same name doesn't collide with it.
+func PageButton(btnIndex int, text string, page esd.Page) Option {
+	return func(bp *bandPage) {
+		bp.pages[btnIndex] = &pageButton{
+			text: text,
+			page: page,
+		}
+	}
 }
 
 type bandButton struct {
@@ -24,29 +72,56 @@ type bandButton struct {
 	label     *label.Label
 }
 
-func NewBandPage(sd *esd.StreamDeck, parent esd.Page, stacks map[string]esd.Page) esd.Page {
+// Band is a button on the band page.
+type Band struct {
+	Name      string
+	ShortName string // max 5 chars
+}
+
+// NewBandPage returns a page with one button per band. The bands map
+// is keyed by the button index. Pressing a band button opens the
+// corresponding page from stacks (key: band name).
+func NewBandPage(sd *esd.StreamDeck, parent esd.Page, bands map[int]Band, stacks map[string]esd.Page, options ...Option) esd.Page {
 
 	bp := &bandPage{
 		sd:        sd,
 		ownParent: parent,
 		stacks:    stacks,
-		labels: map[int]*bandButton{
-			8:  &bandButton{name: "6m", shortName: " 6m "},
-			7:  &bandButton{name: "10m", shortName: " 10m"},
-			6:  &bandButton{name: "15m", shortName: " 15m"},
-			5:  &bandButton{name: "20m", shortName: " 20m"},
-			12: &bandButton{name: "40m", shortName: " 40m"},
-			11: &bandButton{name: "80m", shortName: " 80m"},
-			10: &bandButton{name: "160m", shortName: "160m"},
-		},
+		labels:    make(map[int]*bandButton),
+		toggles:   make(map[int]*toggleButton),
+		pages:     make(map[int]*pageButton),
+	}
+
+	for _, option := range options {
+		option(bp)
 	}
 
-	for pos, l := range bp.labels {
-		tb, err := label.NewLabel(sd, pos, label.Text(l.shortName), label.TextColor(color.RGBA{255, 0, 0, 255}))
+	for pos, t := range bp.toggles {
+		b, err := ledBtn.NewLedButton(sd, pos, ledBtn.Text(t.text), ledBtn.State(t.target.Enabled()))
 		if err != nil {
 			log.Fatal(err)
 		}
-		bp.labels[pos].label = tb
+		t.btn = b
+	}
+
+	for pos, p := range bp.pages {
+		l, err := label.NewLabel(sd, pos, label.Text(p.text), label.TextColor(color.RGBA{255, 0, 0, 255}))
+		if err != nil {
+			log.Fatal(err)
+		}
+		p.label = l
+	}
+
+	for pos, b := range bands {
+		tb, err := label.NewLabel(sd, pos, label.Text(b.ShortName), label.TextColor(color.RGBA{255, 0, 0, 255}))
+		if err != nil {
+			log.Fatal(err)
+		}
+		bp.labels[pos] = &bandButton{
+			name:      b.Name,
+			shortName: b.ShortName,
+			label:     tb,
+		}
 	}
 
 	return bp
@@ -60,6 +135,19 @@ func (bp *bandPage) Set(btnIndex int, state esd.BtnState) esd.Page {
 		return nil
 	}
 
+	if t, ok := bp.toggles[btnIndex]; ok {
+		t.target.SetEnabled(!t.target.Enabled())
+		t.btn.SetState(t.target.Enabled())
+		if bp.active {
+			t.btn.Draw()
+		}
+		return nil
+	}
+
+	if p, ok := bp.pages[btnIndex]; ok {
+		return p.page
+	}
+
 	if bandBtn, ok := bp.labels[btnIndex]; ok {
 		if stack, ok := bp.stacks[bandBtn.name]; ok {
 			return stack
@@ -79,6 +167,13 @@ func (bp *bandPage) draw() {
 	for _, label := range bp.labels {
 		label.label.Draw()
 	}
+	for _, p := range bp.pages {
+		p.label.Draw()
+	}
+	for _, t := range bp.toggles {
+		t.btn.SetState(t.target.Enabled())
+		t.btn.Draw()
+	}
 }
 
 func (bp *bandPage) Draw() {
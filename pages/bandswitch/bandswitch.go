@@ -1,72 +1,377 @@
 package bandswitch
 
 import (
+	"errors"
+	"fmt"
+	"image/color"
 	"log"
 	"sync"
 
+	Switch "github.com/dh1tw/remoteSwitch/switch"
 	esd "github.com/dh1tw/streamdeck"
 	"github.com/dh1tw/streamdeck-buttons/label"
 	ledBtn "github.com/dh1tw/streamdeck-buttons/ledbutton"
 	"github.com/dh1tw/touchctl/hub"
+	"github.com/dh1tw/touchctl/pages/keys"
+	"github.com/dh1tw/touchctl/pages/offline"
+	"github.com/dh1tw/touchctl/tx"
 )
 
+const (
+	portBtn = 4  // toggles the active port
+	backBtn = 14 // returns to the parent page
+)
+
+// errOffline is returned if the bandswitch has not been discovered (yet)
+// or is stale.
+var errOffline = errors.New("bandswitch is offline")
+
+// BandswitchPage selects the antennas of a two radio bandswitch. The
+// antenna buttons always show the state of the active port (radio). The
+// page refuses to assign the same antenna to both ports.
 type BandswitchPage struct {
 	sd *esd.StreamDeck
 	sync.Mutex
 	ownParent  esd.Page
 	labels     map[int]*label.Label
 	hub        *hub.Hub
+	il         *tx.Interlock
 	active     bool
 	activePort string
 	config     BandswitchConfig
+	terminals  map[int]string // key: button index, value: terminal name
 	portABtns  map[int]*ledBtn.LedButton
 	portBBtns  map[int]*ledBtn.LedButton
+	portLabels map[string]*label.Label // key: port name
+	offline    map[int]*label.Label    // key: button index; shown while unavailable
+	bs         Switch.Switcher         // nil while the bandswitch is unavailable
+	redraw     func()
 }
 
-type bandswitchPort struct {
-	sync.Mutex
+type BsTerminal struct {
+	Name      string // Full name
+	ShortName string // max 5 char
+	Button    int
 }
 
 type BandswitchConfig struct {
-	Name              string
-	BandButtonMapping map[string]int
+	Name      string // name of the switch
+	Label     string // shown on the back button
+	Terminals []BsTerminal
 }
 
-func NewBandswitchPage(sd *esd.StreamDeck, parent esd.Page, h *hub.Hub, config BandswitchConfig) *BandswitchPage {
+// NewBandswitchPage returns the page of a bandswitch with the ports A and B.
+// The bandswitch doesn't have to be registered in the hub yet; until it
+// shows up, its buttons are shown as offline (see Refresh). While the
+// interlock il is locked, the bandswitch can not be switched.
+func NewBandswitchPage(sd *esd.StreamDeck, parent esd.Page, h *hub.Hub, il *tx.Interlock, config BandswitchConfig) (*BandswitchPage, error) {
 
 	bsp := &BandswitchPage{
 		sd:         sd,
 		ownParent:  parent,
 		hub:        h,
+		il:         il,
 		config:     config,
 		activePort: "A",
+		labels:     make(map[int]*label.Label),
+		terminals:  make(map[int]string),
+		portABtns:  make(map[int]*ledBtn.LedButton),
+		portBBtns:  make(map[int]*ledBtn.LedButton),
+		portLabels: make(map[string]*label.Label),
+		offline:    make(map[int]*label.Label),
+	}
+
+	for _, t := range bsp.config.Terminals {
+		a, err := ledBtn.NewLedButton(sd, t.Button, ledBtn.Text(t.ShortName), ledBtn.LedColor(ledBtn.LEDGreen))
+		if err != nil {
+			return nil, err
+		}
+		b, err := ledBtn.NewLedButton(sd, t.Button, ledBtn.Text(t.ShortName), ledBtn.LedColor(ledBtn.LEDYellow))
+		if err != nil {
+			return nil, err
+		}
+		ol, err := offline.NewLabel(sd, t.Button)
+		if err != nil {
+			return nil, err
+		}
+		bsp.portABtns[t.Button] = a
+		bsp.portBBtns[t.Button] = b
+		bsp.offline[t.Button] = ol
+		bsp.terminals[t.Button] = t.Name
+	}
+
+	pA, err := label.NewLabel(sd, portBtn, label.Text("A"), label.TextColor(color.RGBA{92, 184, 92, 255}))
+	if err != nil {
+		return nil, err
+	}
+	bsp.portLabels["A"] = pA
+
+	pB, err := label.NewLabel(sd, portBtn, label.Text("B"), label.TextColor(color.RGBA{240, 173, 78, 255}))
+	if err != nil {
+		return nil, err
+	}
+	bsp.portLabels["B"] = pB
+
+	back, err := label.NewLabel(sd, backBtn, label.Text(config.Label), label.TextColor(color.RGBA{255, 0, 0, 255}))
+	if err != nil {
+		return nil, err
+	}
+	bsp.labels[backBtn] = back
+	bsp.redraw = keys.Redraw(bsp, &bsp.active, bsp.draw)
+
+	bsp.refresh()
+
+	return bsp, nil
+}
+
+// btns returns the buttons of a port.
+func (bsp *BandswitchPage) btns(portName string) map[int]*ledBtn.LedButton {
+	if portName == "B" {
+		return bsp.portBBtns
+	}
+	return bsp.portABtns
+}
+
+func otherPort(portName string) string {
+	if portName == "A" {
+		return "B"
+	}
+	return "A"
+}
+
+// update sets the LEDs of a port according to its terminal states.
+func (bsp *BandswitchPage) update(port Switch.Port) {
+	if port.Name != "A" && port.Name != "B" {
+		return
+	}
+	btns := bsp.btns(port.Name)
+	s := states(port)
+	for pos, tName := range bsp.terminals {
+		btns[pos].SetState(s[tName])
+	}
+}
+
+func (bsp *BandswitchPage) Set(btnIndex int, state esd.BtnState) esd.Page {
+	bsp.Lock()
+	defer bsp.Unlock()
+
+	if state == esd.BtnReleased {
+		return nil
+	}
+
+	switch btnIndex {
+	case backBtn:
+		return bsp.ownParent
+	case portBtn:
+		bsp.activePort = otherPort(bsp.activePort)
+		if bsp.active {
+			bsp.draw()
+		}
+		return nil
+	}
+
+	tName, ok := bsp.terminals[btnIndex]
+	if !ok {
+		return nil
+	}
+
+	if err := bsp.set(tName); err != nil {
+		log.Println(err)
+		keys.Flash(bsp.sd, btnIndex, bsp.redraw)
+	}
+
+	return nil
+}
+
+// set toggles the terminal on the active port. The request is refused
+// if the terminal is already selected on the other port. Both checks
+// use the live state of the bandswitch, since the LEDs might lag behind.
+func (bsp *BandswitchPage) set(tName string) error {
+	if err := bsp.il.Check(); err != nil {
+		return err
+	}
+
+	if bsp.bs == nil {
+		return errOffline
+	}
+
+	port, err := bsp.bs.GetPort(bsp.activePort)
+	if err != nil {
+		return err
 	}
 
-	bs, exists := bsp.hub.Switch(bsp.config.Name)
-	if !exists {
-		log.Fatalf("bandswitch '%v' does not exist", bsp.config.Name)
+	p := Switch.Port{
+		Name: bsp.activePort,
+		Terminals: []Switch.Terminal{
+			Switch.Terminal{
+				Name:  tName,
+				State: !states(port)[tName],
+			},
+		},
 	}
 
-	portA, err := bs.GetPort("A")
+	if err := checkPort(bsp.config.Name, bsp.bs, p); err != nil {
+		return err
+	}
+
+	return bsp.bs.SetPort(p)
+}
+
+// states returns the state of each terminal of a port.
+func states(port Switch.Port) map[string]bool {
+	s := make(map[string]bool)
+	for _, t := range port.Terminals {
+		s[t.Name] = t.State
+	}
+	return s
+}
+
+// checkPort verifies that the request p doesn't select a terminal which
+// is already selected on the other port of the bandswitch bs.
+func checkPort(name string, bs Switch.Switcher, p Switch.Port) error {
+	if p.Name != "A" && p.Name != "B" {
+		return fmt.Errorf("%s: unknown port %s", name, p.Name)
+	}
+
+	other := otherPort(p.Name)
+	op, err := bs.GetPort(other)
 	if err != nil {
-		log.Fatalf("port A on bandswitch '%v' does not exist", bsp.config.Name)
+		return err
+	}
+	selected := states(op)
+
+	for _, t := range p.Terminals {
+		if t.State && selected[t.Name] {
+			return fmt.Errorf("%s: %w, %s is already selected on port %s", name, hub.ErrRefused, t.Name, other)
+		}
 	}
 
-	// portB, err := bs.GetPort("B")
-	// if err != nil {
-	// 	log.Fatalf("port B on bandswitch '%v' does not exist", bsp.config.Name)
-	// }
+	return nil
+}
 
-	for _, t := range portA.Terminals {
-		if pos, exists := bsp.config.BandButtonMapping[t.Name]; exists {
-			b, err := ledBtn.NewLedButton(sd, 13, ledBtn.Text(t.Name))
-			if err != nil {
-				log.Fatal(err)
+// EventHandler dispatches the events of the hub.
+func (bsp *BandswitchPage) EventHandler(ev hub.Event) {
+	switch ev.Name {
+	case hub.UpdateSwitch:
+		bsp.SwitchUpdateHandler(ev.Switch, *ev.Device)
+	case hub.AddSwitch, hub.RemoveSwitch, hub.UpdateStale:
+		bsp.Refresh()
+	}
+}
+
+// Refresh looks up the bandswitch in the hub. Until the bandswitch has
+// been discovered, its buttons are shown as offline; while it is stale or
+// after it has disappeared, its buttons are marked with a "?".
+func (bsp *BandswitchPage) Refresh() {
+	bsp.Lock()
+	defer bsp.Unlock()
+	bsp.refresh()
+	if bsp.active {
+		bsp.draw()
+	}
+}
+
+func (bsp *BandswitchPage) refresh() {
+	bs, ok := bsp.hub.Switch(bsp.config.Name)
+	if !ok || bsp.hub.Stale(bsp.config.Name) {
+		if ok || bsp.bs != nil {
+			for _, t := range bsp.config.Terminals {
+				l, err := offline.NewStaleLabel(bsp.sd, t.Button, t.ShortName)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+				bsp.offline[t.Button] = l
 			}
-			b.SetState(t.State)
-			bsp.portABtns[pos] = b
 		}
+		bsp.bs = nil
+		return
+	}
+
+	for _, portName := range []string{"A", "B"} {
+		p, err := bs.GetPort(portName)
+		if err != nil {
+			log.Printf("port %s on bandswitch '%v' does not exist\n", portName, bsp.config.Name)
+			return
+		}
+		bsp.update(p)
+	}
+	bsp.bs = bs
+}
+
+func (bsp *BandswitchPage) SwitchUpdateHandler(s Switch.Switcher, device Switch.Device) {
+	bsp.Lock()
+	defer bsp.Unlock()
+	if device.Name != bsp.config.Name || bsp.bs == nil {
+		return
+	}
+
+	for _, portName := range []string{"A", "B"} {
+		p, err := s.GetPort(portName)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		bsp.update(p)
 	}
 
-	return bsp
+	if bsp.active {
+		for _, btn := range bsp.btns(bsp.activePort) {
+			btn.Draw()
+		}
+	}
+}
+
+// CheckPort returns an error if the request p to set a port of the switch
+// s (e.g. through the HTTP API) would select an antenna which is already
+// selected on the other port of the bandswitch. Requests for other
+// switches are accepted.
+func (bsp *BandswitchPage) CheckPort(s Switch.Switcher, p Switch.Port) error {
+	if s.Name() != bsp.config.Name {
+		return nil
+	}
+	return checkPort(bsp.config.Name, s, p)
+}
+
+func (bsp *BandswitchPage) SetActive(active bool) {
+	bsp.Lock()
+	defer bsp.Unlock()
+	bsp.active = active
+}
+
+func (bsp *BandswitchPage) draw() {
+	for _, l := range bsp.labels {
+		l.Draw()
+	}
+
+	bsp.portLabels[bsp.activePort].Draw()
+
+	if bsp.bs == nil {
+		for _, l := range bsp.offline {
+			l.Draw()
+		}
+		return
+	}
+
+	for _, btn := range bsp.btns(bsp.activePort) {
+		btn.Draw()
+	}
+}
+
+func (bsp *BandswitchPage) Draw() {
+	bsp.Lock()
+	defer bsp.Unlock()
+	bsp.draw()
+}
+
+func (bsp *BandswitchPage) Parent() esd.Page {
+	bsp.Lock()
+	defer bsp.Unlock()
+	return bsp.ownParent
+}
+
+func (bsp *BandswitchPage) SetParent(parent esd.Page) {
+	bsp.Lock()
+	defer bsp.Unlock()
+	bsp.ownParent = parent
 }
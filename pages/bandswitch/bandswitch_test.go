@@ -0,0 +1,156 @@
+package bandswitch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	Switch "github.com/dh1tw/remoteSwitch/switch"
+	esd "github.com/dh1tw/streamdeck"
+	"github.com/dh1tw/touchctl/hub"
+	"github.com/dh1tw/touchctl/internal/switchtest"
+	"github.com/dh1tw/touchctl/tx"
+)
+
+// newSwitch returns a bandswitch on which 10m is selected on port A and
+// 15m on port B.
+func newSwitch() *switchtest.Fake {
+	return switchtest.New("Bandswitch",
+		Switch.Port{Name: "A", Terminals: []Switch.Terminal{
+			{Name: "10m", State: true}, {Name: "15m"}, {Name: "20m"},
+		}},
+		Switch.Port{Name: "B", Terminals: []Switch.Terminal{
+			{Name: "10m"}, {Name: "15m", State: true}, {Name: "20m"},
+		}},
+	)
+}
+
+func TestCheckPort(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		port      string
+		terminals []Switch.Terminal
+		err       string // "": accepted
+	}{
+		{"select free antenna", "A", []Switch.Terminal{{Name: "20m", State: true}}, ""},
+		{"select antenna of port B", "A", []Switch.Terminal{{Name: "15m", State: true}}, "refused, 15m is already selected on port B"},
+		{"select antenna of port A", "B", []Switch.Terminal{{Name: "10m", State: true}}, "refused, 10m is already selected on port A"},
+		{"deselect antenna", "B", []Switch.Terminal{{Name: "15m"}}, ""},
+		{"deselect antenna of the other port", "A", []Switch.Terminal{{Name: "15m"}}, ""},
+		{"select own antenna again", "A", []Switch.Terminal{{Name: "10m", State: true}}, ""},
+		{"one of several antennas conflicts", "A", []Switch.Terminal{{Name: "20m", State: true}, {Name: "15m", State: true}}, "refused, 15m"},
+		{"unknown port", "C", []Switch.Terminal{{Name: "20m", State: true}}, "unknown port C"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkPort("Bandswitch", newSwitch(), Switch.Port{Name: tc.port, Terminals: tc.terminals})
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("got error %v, want '%s'", err, tc.err)
+			}
+			if refused := errors.Is(err, hub.ErrRefused); refused != strings.HasPrefix(tc.err, "refused") {
+				t.Fatalf("got refused %v for %v", refused, err)
+			}
+		})
+	}
+
+	// the live state of the switch is checked
+	bs := newSwitch()
+	bs.SetErr(errors.New("connection lost"))
+	if err := checkPort("Bandswitch", bs, Switch.Port{Name: "A"}); err == nil || errors.Is(err, hub.ErrRefused) {
+		t.Fatalf("got %v, want the connection error", err)
+	}
+}
+
+func TestSet(t *testing.T) {
+
+	h, err := hub.NewHub()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := newSwitch()
+	if err := h.AddSwitch(bs); err != nil {
+		t.Fatal(err)
+	}
+	il := tx.NewInterlock()
+
+	// the page is never drawn, so no Stream Deck is needed
+	bsp, err := NewBandswitchPage(&esd.StreamDeck{}, nil, h, il, BandswitchConfig{
+		Name:  "Bandswitch",
+		Label: " BS ",
+		Terminals: []BsTerminal{
+			{Name: "10m", ShortName: " 10m", Button: 7},
+			{Name: "15m", ShortName: " 15m", Button: 6},
+			{Name: "20m", ShortName: " 20m", Button: 5},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name     string
+		port     string
+		terminal string
+		external func() // changes the switch behind the back of the page
+		locked   bool
+		ok       bool
+		a, b     string // selected antennas after the step
+	}{
+		{name: "select free antenna on A", port: "A", terminal: "20m", ok: true, a: "10m 20m", b: "15m"},
+		{name: "select antenna of A on B", port: "B", terminal: "20m", a: "10m 20m", b: "15m"},
+		{name: "deselect antenna on A", port: "A", terminal: "20m", ok: true, a: "10m", b: "15m"},
+		{name: "TX lock", port: "B", terminal: "20m", locked: true, a: "10m", b: "15m"},
+		{name: "select free antenna on B", port: "B", terminal: "20m", ok: true, a: "10m", b: "15m 20m"},
+		{name: "changed on the switch", port: "B", terminal: "10m",
+			external: func() {
+				bs.SetPort(Switch.Port{Name: "A", Terminals: []Switch.Terminal{{Name: "10m"}}})
+			},
+			ok: true, a: "", b: "10m 15m 20m"},
+	}
+
+	for _, st := range steps {
+		if st.external != nil {
+			st.external()
+		}
+		il.Set("test", st.locked)
+
+		bsp.Lock()
+		bsp.activePort = st.port
+		err := bsp.set(st.terminal)
+		bsp.Unlock()
+
+		if st.ok && err != nil {
+			t.Fatalf("%s: unexpected error: %v", st.name, err)
+		}
+		if !st.ok && err == nil {
+			t.Fatalf("%s: request accepted", st.name)
+		}
+		if st.locked && err != tx.ErrLocked {
+			t.Fatalf("%s: got %v, want %v", st.name, err, tx.ErrLocked)
+		}
+		for port, want := range map[string]string{"A": st.a, "B": st.b} {
+			if got := selected(bs, port); got != want {
+				t.Fatalf("%s: port %s: got '%s', want '%s'", st.name, port, got, want)
+			}
+		}
+	}
+}
+
+// selected returns the selected antennas of a port.
+func selected(bs *switchtest.Fake, port string) string {
+	res := []string{}
+	for _, t := range []string{"10m", "15m", "20m"} {
+		if bs.State(port, t) {
+			res = append(res, t)
+		}
+	}
+	return strings.Join(res, " ")
+}
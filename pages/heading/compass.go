@@ -0,0 +1,129 @@
+package heading
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	esd "github.com/dh1tw/streamdeck"
+	"golang.org/x/image/vector"
+)
+
+var (
+	roseColor  = color.RGBA{96, 96, 96, 255}
+	northColor = color.RGBA{255, 0, 0, 255}
+	ghostColor = color.RGBA{46, 92, 46, 128} // targetColor, half transparent
+)
+
+// RenderCompass draws the heading as a compass rose on an image of the
+// size of a button. The needle points to the current azimuth; while the
+// rotator is turning, a ghost needle points to the target.
+func RenderCompass(h rotator.Heading, dir Direction, longPath bool) *image.RGBA {
+	size := esd.ButtonSize
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(bgColor), image.Point{}, draw.Src)
+
+	if longPath {
+		drawLongPath(img)
+	}
+
+	c := float32(size) / 2
+	radius := c - 2
+
+	// ring
+	z := newRasterizer(size)
+	circle(z, c, c, radius, false)
+	circle(z, c, c, radius-2, true)
+	z.Draw(img, img.Bounds(), image.NewUniform(roseColor), image.Point{})
+
+	// ticks every 45°; north is marked by the letter
+	for az := 45; az < 360; az += 45 {
+		length := float32(4)
+		if az%90 == 0 {
+			length = 7
+		}
+		z := newRasterizer(size)
+		tick(z, c, c, az, radius-2-length, radius-2)
+		z.Draw(img, img.Bounds(), image.NewUniform(roseColor), image.Point{})
+	}
+	drawText(img, "N", 11, 15, northColor)
+
+	if Turning(h) {
+		z := newRasterizer(size)
+		needle(z, c, c, h.AzPreset, radius-6, 4, 3)
+		z.Draw(img, img.Bounds(), image.NewUniform(ghostColor), image.Point{})
+	}
+
+	col := headingColor
+	if Turning(h) {
+		col = turningColor
+	}
+	z = newRasterizer(size)
+	needle(z, c, c, h.Azimuth, radius-6, 8, 4)
+	z.Draw(img, img.Bounds(), image.NewUniform(col), image.Point{})
+
+	// hub
+	z = newRasterizer(size)
+	circle(z, c, c, 2.5, false)
+	z.Draw(img, img.Bounds(), image.NewUniform(bgColor), image.Point{})
+
+	return img
+}
+
+func newRasterizer(size int) *vector.Rasterizer {
+	return vector.NewRasterizer(size, size)
+}
+
+// point returns the position at distance r from (cx, cy) in the direction
+// of the azimuth az (degrees, clockwise from north).
+func point(cx, cy float32, az float64, r float32) (float32, float32) {
+	rad := az * math.Pi / 180
+	return cx + r*float32(math.Sin(rad)), cy - r*float32(math.Cos(rad))
+}
+
+// needle adds a kite shaped needle pointing to az. The tip is at
+// distance length from the center, the tail at distance tail on the
+// opposite side; width is half of the needle's width at the center.
+func needle(z *vector.Rasterizer, cx, cy float32, az int, length, tail, width float32) {
+	a := float64(az)
+	z.MoveTo(point(cx, cy, a, length))
+	z.LineTo(point(cx, cy, a+90, width))
+	z.LineTo(point(cx, cy, a+180, tail))
+	z.LineTo(point(cx, cy, a-90, width))
+	z.ClosePath()
+}
+
+// tick adds a radial tick mark from r1 to r2 at az.
+func tick(z *vector.Rasterizer, cx, cy float32, az int, r1, r2 float32) {
+	const width = 1
+	a := float64(az) * math.Pi / 180
+	dx, dy := width*float32(math.Cos(a)), width*float32(math.Sin(a))
+	x1, y1 := point(cx, cy, float64(az), r1)
+	x2, y2 := point(cx, cy, float64(az), r2)
+	z.MoveTo(x1-dx, y1-dy)
+	z.LineTo(x2-dx, y2-dy)
+	z.LineTo(x2+dx, y2+dy)
+	z.LineTo(x1+dx, y1+dy)
+	z.ClosePath()
+}
+
+// circle adds a circle as polygon. The winding of the inner circle of a
+// ring has to be reversed so that it cuts out the inner area.
+func circle(z *vector.Rasterizer, cx, cy, r float32, reverse bool) {
+	const steps = 64
+	for i := 0; i <= steps; i++ {
+		az := float64(i) * 360 / steps
+		if reverse {
+			az = -az
+		}
+		x, y := point(cx, cy, az, r)
+		if i == 0 {
+			z.MoveTo(x, y)
+			continue
+		}
+		z.LineTo(x, y)
+	}
+	z.ClosePath()
+}
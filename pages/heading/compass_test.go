@@ -0,0 +1,102 @@
+package heading
+
+import (
+	"flag"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+var update = flag.Bool("update", false, "update the golden images in testdata")
+
+// maxDelta is the maximum difference of a color channel to the golden
+// image. It allows for rounding differences of the rasterizer between
+// platforms.
+const maxDelta = 8
+
+func TestRenderCompass(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		heading  rotator.Heading
+		dir      Direction
+		longPath bool
+	}{
+		{"idle", rotator.Heading{Azimuth: 45, AzPreset: 45}, Stopped, false},
+		{"turning_cw", rotator.Heading{Azimuth: 100, AzPreset: 200}, CW, false},
+		{"turning_ccw", rotator.Heading{Azimuth: 300, AzPreset: 200}, CCW, false},
+		{"long_path", rotator.Heading{Azimuth: 225, AzPreset: 225}, Stopped, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			img := RenderCompass(tc.heading, tc.dir, tc.longPath)
+			golden := filepath.Join("testdata", "compass_"+tc.name+".png")
+
+			if *update {
+				writePNG(t, golden, img)
+				return
+			}
+
+			want := readPNG(t, golden)
+			if !img.Bounds().Eq(want.Bounds()) {
+				t.Fatalf("got size %v, want %v", img.Bounds(), want.Bounds())
+			}
+			for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+				for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+					r1, g1, b1, a1 := img.At(x, y).RGBA()
+					r2, g2, b2, a2 := want.At(x, y).RGBA()
+					if differs(r1, r2) || differs(g1, g2) || differs(b1, b2) || differs(a1, a2) {
+						t.Fatalf("pixel (%d, %d) differs from %s; run the test with -update if the change is intended",
+							x, y, golden)
+					}
+				}
+			}
+		})
+	}
+}
+
+// differs compares two 16 bit color channels with a tolerance of
+// maxDelta (8 bit).
+func differs(a, b uint32) bool {
+	a, b = a>>8, b>>8
+	if a > b {
+		return a-b > maxDelta
+	}
+	return b-a > maxDelta
+}
+
+func readPNG(t *testing.T, path string) image.Image {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func writePNG(t *testing.T, path string, img image.Image) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
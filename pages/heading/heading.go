@@ -0,0 +1,254 @@
+// Package heading renders the heading of a rotator on a Stream Deck
+// button: the current azimuth, and while the rotator is turning, the
+// target azimuth and an arrow indicating the direction of movement.
+// Alternatively the heading can be drawn as a compass rose. Antennas
+// which are pointed on the long path are marked with "LP".
+package heading
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	esd "github.com/dh1tw/streamdeck"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/math/fixed"
+)
+
+// Direction is the direction in which a rotator is turning.
+type Direction int
+
+const (
+	Stopped Direction = iota
+	CW
+	CCW
+)
+
+// tolerance is the maximum difference (degrees) between the azimuth and
+// the target for the rotator to be considered as stopped.
+const tolerance = 2
+
+var (
+	headingColor = color.RGBA{255, 255, 255, 255}
+	turningColor = color.RGBA{240, 173, 78, 255}
+	targetColor  = color.RGBA{92, 184, 92, 255}
+	lpColor      = color.RGBA{91, 192, 222, 255}
+	bgColor      = color.RGBA{0, 0, 0, 255}
+)
+
+var ttf *truetype.Font
+
+func init() {
+	var err error
+	ttf, err = truetype.Parse(gomonobold.TTF)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Turning returns true if the rotator has not reached its target yet.
+func Turning(h rotator.Heading) bool {
+	return delta(h.Azimuth, h.AzPreset) > tolerance || delta(h.Azimuth, h.AzPreset) < -tolerance
+}
+
+// Reciprocal returns the long path bearing of az (0...359).
+func Reciprocal(az int) int {
+	return (az + 180) % 360
+}
+
+// delta returns the shortest angular distance from a to b (-180..180).
+func delta(a, b int) int {
+	d := (b - a) % 360
+	if d > 180 {
+		d -= 360
+	}
+	if d < -180 {
+		d += 360
+	}
+	return d
+}
+
+// Render draws the heading on an image of the size of a button.
+func Render(h rotator.Heading, dir Direction, longPath bool) *image.RGBA {
+	size := esd.ButtonSize
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(bgColor), image.Point{}, draw.Src)
+
+	if longPath {
+		drawLongPath(img)
+	}
+
+	if !Turning(h) {
+		drawText(img, fmt.Sprintf("%03d°", h.Azimuth), 24, size/2+9, headingColor)
+		return img
+	}
+
+	drawArrow(img, dir)
+	drawText(img, fmt.Sprintf("%03d°", h.Azimuth), 22, size/2+8, turningColor)
+	drawText(img, fmt.Sprintf("»%03d°", h.AzPreset), 14, size-6, targetColor)
+
+	return img
+}
+
+// drawText draws text horizontally centered with its baseline at y.
+func drawText(img *image.RGBA, text string, size float64, y int, c color.Color) {
+	face := truetype.NewFace(ttf, &truetype.Options{Size: size, DPI: 72})
+	defer face.Close()
+
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: face,
+	}
+	width := d.MeasureString(text)
+	d.Dot = fixed.Point26_6{
+		X: (fixed.I(img.Bounds().Dx()) - width) / 2,
+		Y: fixed.I(y),
+	}
+	d.DrawString(text)
+}
+
+// drawLongPath marks the image with "LP" in the bottom left corner.
+func drawLongPath(img *image.RGBA) {
+	face := truetype.NewFace(ttf, &truetype.Options{Size: 10, DPI: 72})
+	defer face.Close()
+
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(lpColor),
+		Face: face,
+		Dot:  fixed.P(1, img.Bounds().Dy()-2),
+	}
+	d.DrawString("LP")
+}
+
+// drawArrow draws an arrow at the top of the button which points to the
+// right (CW) or to the left (CCW).
+func drawArrow(img *image.RGBA, dir Direction) {
+	if dir == Stopped {
+		return
+	}
+
+	const (
+		top    = 5
+		height = 11
+		left   = 16
+		right  = esd.ButtonSize - 16
+	)
+	mid := top + height/2
+
+	// shaft
+	for x := left; x <= right; x++ {
+		for y := mid - 1; y <= mid+1; y++ {
+			img.Set(x, y, turningColor)
+		}
+	}
+
+	// head; the tip is at the end of the shaft
+	half := height / 2
+	for i := 0; i <= half; i++ {
+		x := right - half + i
+		if dir == CCW {
+			x = left + half - i
+		}
+		for y := mid - (half - i); y <= mid+(half-i); y++ {
+			img.Set(x, y, turningColor)
+		}
+	}
+}
+
+// RenderFunc draws a heading on an image of the size of a button.
+type RenderFunc func(h rotator.Heading, dir Direction, longPath bool) *image.RGBA
+
+// Widget keeps track of the heading of a rotator and derives the
+// direction of movement from consecutive updates.
+type Widget struct {
+	sync.Mutex
+	heading  rotator.Heading
+	dir      Direction
+	longPath bool
+	img      *image.RGBA
+	render   RenderFunc
+}
+
+// NewWidget returns a Widget showing the heading h. Functional options
+// can be supplied to modify the default behaviour.
+func NewWidget(h rotator.Heading, opts ...func(*Widget)) *Widget {
+	w := &Widget{
+		render: Render,
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	w.Update(h)
+	return w
+}
+
+// Renderer is a functional option which sets the function drawing the
+// Widget (default: Render).
+func Renderer(r RenderFunc) func(*Widget) {
+	return func(w *Widget) {
+		w.render = r
+	}
+}
+
+// Update sets the heading of the rotator.
+func (w *Widget) Update(h rotator.Heading) {
+	w.Lock()
+	defer w.Unlock()
+
+	switch {
+	case !Turning(h):
+		w.dir = Stopped
+	case h.Azimuth != w.heading.Azimuth && w.img != nil:
+		// the rotator doesn't necessarily take the shortest way to its
+		// target (stops, overlap), so the movement decides
+		if delta(w.heading.Azimuth, h.Azimuth) > 0 {
+			w.dir = CW
+		} else {
+			w.dir = CCW
+		}
+	case w.dir == Stopped:
+		if delta(h.Azimuth, h.AzPreset) > 0 {
+			w.dir = CW
+		} else {
+			w.dir = CCW
+		}
+	}
+
+	w.heading = h
+	w.img = w.render(h, w.dir, w.longPath)
+}
+
+// SetLongPath marks the heading as long path.
+func (w *Widget) SetLongPath(longPath bool) {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.longPath == longPath {
+		return
+	}
+	w.longPath = longPath
+	w.img = w.render(w.heading, w.dir, w.longPath)
+}
+
+// Heading returns the last known heading.
+func (w *Widget) Heading() rotator.Heading {
+	w.Lock()
+	defer w.Unlock()
+	return w.heading
+}
+
+// Draw renders the widget on a button.
+func (w *Widget) Draw(sd *esd.StreamDeck, btnIndex int) error {
+	w.Lock()
+	defer w.Unlock()
+	return sd.FillImage(btnIndex, w.img)
+}
@@ -0,0 +1,144 @@
+package keys
+
+import (
+	"image/color"
+	"log"
+	"sync"
+
+	esd "github.com/dh1tw/streamdeck"
+	"github.com/dh1tw/streamdeck-buttons/label"
+)
+
+// Display is a key which shows a text that may be empty, e.g. the entry
+// of a keypad. A label refuses to draw an empty text and leaves the key
+// unchanged; an empty Display is filled with its background color
+// instead.
+type Display struct {
+	sd       *esd.StreamDeck
+	btnIndex int
+	bg       color.RGBA
+	label    *label.Label
+	text     string
+}
+
+// NewDisplay returns an empty Display on the key btnIndex.
+func NewDisplay(sd *esd.StreamDeck, btnIndex int, bg, fg color.RGBA) (*Display, error) {
+	l, err := label.NewLabel(sd, btnIndex, label.BgColor(bg), label.TextColor(fg))
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Display{
+		sd:       sd,
+		btnIndex: btnIndex,
+		bg:       bg,
+		label:    l,
+	}
+	return d, nil
+}
+
+// SetText sets the text of the Display. Call Draw to show it.
+func (d *Display) SetText(text string) {
+	d.text = text
+	d.label.SetText(text)
+}
+
+// Text returns the text of the Display.
+func (d *Display) Text() string {
+	return d.text
+}
+
+// Draw draws the text, or the background if the text is empty.
+func (d *Display) Draw() error {
+	if d.text == "" {
+		return d.sd.FillColor(d.btnIndex, int(d.bg.R), int(d.bg.G), int(d.bg.B))
+	}
+	return d.label.Draw()
+}
+
+// Entry is the display key of a page on which a text is entered, e.g. a
+// heading or a locator. It shows the text, or ERR for a moment if the
+// text has been refused. A short press on the key deletes the last
+// character, holding it clears the text.
+//
+// page is the lock of the page and active its flag which tells if the
+// page is shown. Except for NewEntry, the methods must be called while
+// holding the lock.
+type Entry struct {
+	page     sync.Locker
+	active   *bool
+	display  *Display
+	errLabel *label.Label
+	hold     Hold
+}
+
+// NewEntry returns an empty Entry on the key btnIndex.
+func NewEntry(sd *esd.StreamDeck, btnIndex int, page sync.Locker, active *bool) (*Entry, error) {
+	display, err := NewDisplay(sd, btnIndex,
+		color.RGBA{0, 255, 0, 255},
+		color.RGBA{0, 0, 0, 255})
+	if err != nil {
+		return nil, err
+	}
+
+	errLabel, err := label.NewLabel(sd, btnIndex,
+		label.Text("ERR"),
+		label.BgColor(color.RGBA{255, 0, 0, 255}),
+		label.TextColor(color.RGBA{255, 255, 255, 255}))
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Entry{
+		page:     page,
+		active:   active,
+		display:  display,
+		errLabel: errLabel,
+	}
+	return e, nil
+}
+
+// SetText shows text on the key if the page is shown.
+func (e *Entry) SetText(text string) {
+	e.display.SetText(text)
+	if *e.active {
+		e.Draw()
+	}
+}
+
+// Draw draws the entered text.
+func (e *Entry) Draw() {
+	if err := e.display.Draw(); err != nil {
+		log.Println(err)
+	}
+}
+
+// ShowError shows ERR on the key for FlashDuration.
+func (e *Entry) ShowError() {
+	if err := e.errLabel.Draw(); err != nil {
+		log.Println(err)
+	}
+	After(Redraw(e.page, e.active, e.Draw))
+}
+
+// Edit handles the key: a short press calls backspace, holding it for
+// LongPress calls clear. Both are called while holding the lock of
+// the page.
+func (e *Entry) Edit(state esd.BtnState, backspace, clear func()) {
+	if state == esd.BtnPressed {
+		e.hold.Press(func() {
+			e.page.Lock()
+			defer e.page.Unlock()
+			clear()
+		})
+		return
+	}
+	if e.hold.Release() {
+		backspace()
+	}
+}
+
+// Stop cancels holding the key, e.g. when the page is left.
+func (e *Entry) Stop() {
+	e.hold.Stop()
+}
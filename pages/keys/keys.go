@@ -0,0 +1,87 @@
+// Package keys contains the key feedback and the key gestures which are
+// shared by the pages: flashing a key when a request has been refused,
+// telling a short press from holding a key, display keys which may be
+// empty and the entry keys of the keypads.
+package keys
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	esd "github.com/dh1tw/streamdeck"
+)
+
+// FlashDuration is the time a key is flashed when a request has been
+// refused.
+const FlashDuration = time.Millisecond * 400
+
+// LongPress is the time a key has to be held to trigger its second
+// function (e.g. clearing an entry instead of deleting a character).
+const LongPress = time.Millisecond * 700
+
+// Flash colors the key red for a moment to indicate that the request
+// has been refused. Afterwards restore is called to redraw the key.
+func Flash(sd *esd.StreamDeck, btnIndex int, restore func()) {
+	if err := sd.FillColor(btnIndex, 255, 0, 0); err != nil {
+		log.Println(err)
+	}
+	After(restore)
+}
+
+// After calls f in its own goroutine once FlashDuration has elapsed,
+// e.g. to restore a key which shows an error.
+func After(f func()) {
+	go func() {
+		time.Sleep(FlashDuration)
+		f()
+	}()
+}
+
+// Redraw returns a function which draws a page again, e.g. to restore
+// a key after it has been flashed. page is the lock of the page and
+// active its flag which tells if the page is shown; the page is only
+// drawn if it hasn't been left in the meantime.
+func Redraw(page sync.Locker, active *bool, draw func()) func() {
+	return func() {
+		page.Lock()
+		defer page.Unlock()
+		if *active {
+			draw()
+		}
+	}
+}
+
+// Hold tells a short press of a key from holding it for LongPress. The
+// zero value is ready to use. Hold is not safe for concurrent use; the
+// pages call it while holding their lock.
+type Hold struct {
+	timer *time.Timer
+}
+
+// Press starts the timer when the key is pressed. long is called in
+// its own goroutine once the key has been held for LongPress.
+func (h *Hold) Press(long func()) {
+	h.Stop()
+	h.timer = time.AfterFunc(LongPress, long)
+}
+
+// Release stops the timer when the key is released. It returns true if
+// the key has been released before LongPress, and false if it has been
+// held or has not been pressed at all.
+func (h *Hold) Release() bool {
+	if h.timer == nil {
+		return false
+	}
+	short := h.timer.Stop()
+	h.timer = nil
+	return short
+}
+
+// Stop cancels the timer, e.g. when the page is left.
+func (h *Hold) Stop() {
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
+}
@@ -0,0 +1,86 @@
+package keys
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	esd "github.com/dh1tw/streamdeck"
+)
+
+func TestRedraw(t *testing.T) {
+
+	var mu sync.Mutex
+	active := false
+	draws := 0
+	redraw := Redraw(&mu, &active, func() { draws++ })
+
+	redraw()
+	if draws != 0 {
+		t.Fatalf("page has been left, got %d draws", draws)
+	}
+
+	active = true
+	redraw()
+	if draws != 1 {
+		t.Fatalf("got %d draws, want 1", draws)
+	}
+}
+
+func TestEntryEdit(t *testing.T) {
+
+	// the page is never shown, so nothing is drawn
+	var mu sync.Mutex
+	active := false
+	e, err := NewEntry(&esd.StreamDeck{}, 0, &mu, &active)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := "JO62"
+	cleared := make(chan struct{})
+	backspace := func() { text = text[:len(text)-1] }
+	clear := func() {
+		text = ""
+		close(cleared)
+	}
+
+	mu.Lock()
+	e.Edit(esd.BtnPressed, backspace, clear)
+	e.Edit(esd.BtnReleased, backspace, clear)
+	got := text
+	mu.Unlock()
+	if got != "JO6" {
+		t.Fatalf("short press: got %q, want JO6", got)
+	}
+
+	mu.Lock()
+	e.Edit(esd.BtnPressed, backspace, clear)
+	mu.Unlock()
+	select {
+	case <-cleared:
+	case <-time.After(LongPress * 2):
+		t.Fatal("holding the key didn't clear the text")
+	}
+	mu.Lock()
+	e.Edit(esd.BtnReleased, backspace, clear)
+	got = text
+	mu.Unlock()
+	if got != "" {
+		t.Fatalf("long press: got %q, want an empty text", got)
+	}
+
+	// leaving the page cancels holding the key
+	mu.Lock()
+	text = "JO"
+	e.Edit(esd.BtnPressed, backspace, func() { text = "" })
+	e.Stop()
+	mu.Unlock()
+	time.Sleep(LongPress + time.Millisecond*100)
+	mu.Lock()
+	got = text
+	mu.Unlock()
+	if got != "JO" {
+		t.Fatalf("after Stop: got %q, want JO", got)
+	}
+}
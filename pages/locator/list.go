@@ -0,0 +1,125 @@
+package locatorpage
+
+import (
+	"log"
+	"sync"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	esd "github.com/dh1tw/streamdeck"
+	"github.com/dh1tw/streamdeck-buttons/label"
+	"github.com/dh1tw/touchctl/pages/keys"
+	"github.com/dh1tw/touchctl/tx"
+)
+
+// listBtns are the keys of the list page in the order in which the
+// entries are placed.
+var listBtns = []int{3, 2, 1, 0, 8, 7, 6, 5, 13, 12, 11, 10, 9, 14}
+
+// listPage shows the configured locations. Pressing a key turns the
+// rotator towards the location.
+type listPage struct {
+	sync.Mutex
+	sd        *esd.StreamDeck
+	ownParent esd.Page
+	done      esd.Page
+	rotator   rotator.Rotator
+	il        *tx.Interlock
+	config    *Config
+	btns      map[int]*label.Label
+	entries   map[int]Entry // key: button index
+	active    bool
+	redraw    func()
+}
+
+func newListPage(sd *esd.StreamDeck, parent, done esd.Page, r rotator.Rotator, il *tx.Interlock, config *Config) esd.Page {
+
+	lp := &listPage{
+		sd:        sd,
+		ownParent: parent,
+		done:      done,
+		rotator:   r,
+		il:        il,
+		config:    config,
+		btns:      make(map[int]*label.Label),
+		entries:   make(map[int]Entry),
+	}
+
+	for i, e := range config.Entries {
+		if i >= len(listBtns) {
+			log.Printf("locator list: no key left for %s\n", e.Name)
+			break
+		}
+		pos := listBtns[i]
+		l, err := label.NewLabel(sd, pos, label.Text(e.Name))
+		if err != nil {
+			log.Panic(err)
+		}
+		lp.btns[pos] = l
+		lp.entries[pos] = e
+	}
+
+	back, err := label.NewLabel(sd, backBtn, label.Text("BACK"))
+	if err != nil {
+		log.Panic(err)
+	}
+	lp.btns[backBtn] = back
+	lp.redraw = keys.Redraw(lp, &lp.active, lp.draw)
+
+	return lp
+}
+
+func (lp *listPage) Set(btnIndex int, state esd.BtnState) esd.Page {
+	lp.Lock()
+	defer lp.Unlock()
+
+	if state == esd.BtnReleased {
+		return nil
+	}
+
+	if btnIndex == backBtn {
+		return lp.ownParent
+	}
+
+	e, ok := lp.entries[btnIndex]
+	if !ok {
+		return nil
+	}
+
+	if err := lp.il.Check(); err != nil {
+		log.Println(err)
+		keys.Flash(lp.sd, btnIndex, lp.redraw)
+		return nil
+	}
+
+	if err := turn(lp.rotator, lp.config.Bearing(e.Point)); err != nil {
+		log.Println(err)
+		keys.Flash(lp.sd, btnIndex, lp.redraw)
+		return nil
+	}
+
+	return lp.done
+}
+
+func (lp *listPage) draw() {
+	for _, btn := range lp.btns {
+		btn.Draw()
+	}
+}
+
+func (lp *listPage) Draw() {
+	lp.Lock()
+	defer lp.Unlock()
+	lp.draw()
+}
+
+func (lp *listPage) Parent() esd.Page {
+	lp.Lock()
+	defer lp.Unlock()
+	return lp.ownParent
+}
+
+func (lp *listPage) SetActive(active bool) {
+	lp.Lock()
+	defer lp.Unlock()
+	lp.active = active
+}
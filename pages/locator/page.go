@@ -0,0 +1,295 @@
+// Package locatorpage points a rotator at a Maidenhead grid square. The
+// locator is entered on a multi-tap keypad (like on a phone) or picked
+// from a list of configured locations; the rotator is turned to the
+// short path bearing from the QTH.
+package locatorpage
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	esd "github.com/dh1tw/streamdeck"
+	"github.com/dh1tw/streamdeck-buttons/label"
+	"github.com/dh1tw/touchctl/azimuth"
+	"github.com/dh1tw/touchctl/geo"
+	"github.com/dh1tw/touchctl/pages/keys"
+	"github.com/dh1tw/touchctl/pages/multitap"
+	"github.com/dh1tw/touchctl/tx"
+)
+
+// locatorLen is the length of a grid square (e.g. JO62).
+const locatorLen = 4
+
+// button indexes on the locator page
+const (
+	displayBtn = 0
+	backBtn    = 4
+	setBtn     = 5
+	listBtn    = 9
+	bearingBtn = 14
+)
+
+// Entry is a location which can be picked on the list page.
+type Entry struct {
+	Name  string // max 5 chars
+	Point geo.Point
+}
+
+// Config contains the location of the station and the entries of the
+// list page.
+type Config struct {
+	QTH     geo.Point
+	Entries []Entry
+}
+
+// Bearing returns the short path bearing from the QTH to p, rounded
+// to full degrees (0...359).
+func (c *Config) Bearing(p geo.Point) int {
+	return int(math.Round(geo.Bearing(c.QTH, p))) % 360
+}
+
+// locatorChars returns the characters which the key k can enter at the
+// position pos of a locator: letters A...R for the field (first two
+// characters), digits for the square.
+func locatorChars(pos int, k multitap.Key) string {
+	switch {
+	case pos < 2:
+		letters := ""
+		for _, l := range k.Letters {
+			if l <= 'R' {
+				letters += string(l)
+			}
+		}
+		return letters
+	case pos < locatorLen:
+		return strconv.Itoa(k.Digit)
+	}
+	return ""
+}
+
+type locatorPage struct {
+	sync.Mutex
+	sd        *esd.StreamDeck
+	ownParent esd.Page
+	done      esd.Page // shown once the rotator has been turned
+	rotator   rotator.Rotator
+	il        *tx.Interlock
+	config    *Config
+	btns      map[int]*label.Label
+	locator   *keys.Entry
+	bearing   *keys.Display // empty until the locator is complete
+	input     *multitap.Input
+	active    bool
+	redraw    func()
+}
+
+// NewLocatorPage returns the page to enter the locator the rotator r
+// shall point at. Once the rotator has been turned, the page done is
+// shown.
+func NewLocatorPage(sd *esd.StreamDeck, parent, done esd.Page, r rotator.Rotator, il *tx.Interlock, config *Config) esd.Page {
+
+	lp := &locatorPage{
+		sd:        sd,
+		ownParent: parent,
+		done:      done,
+		rotator:   r,
+		il:        il,
+		config:    config,
+		btns:      make(map[int]*label.Label),
+		input:     multitap.NewInput(locatorLen, locatorChars),
+	}
+
+	for pos, k := range multitap.Keys {
+		l, err := label.NewLabel(sd, pos, label.Text(k.Text()))
+		if err != nil {
+			log.Panic(err)
+		}
+		lp.btns[pos] = l
+	}
+
+	texts := map[int]string{
+		backBtn: "BACK",
+		setBtn:  "SET",
+	}
+	if len(config.Entries) > 0 {
+		texts[listBtn] = "LIST"
+	}
+	for pos, text := range texts {
+		l, err := label.NewLabel(sd, pos, label.Text(text))
+		if err != nil {
+			log.Panic(err)
+		}
+		lp.btns[pos] = l
+	}
+
+	locator, err := keys.NewEntry(sd, displayBtn, lp, &lp.active)
+	if err != nil {
+		log.Panic(err)
+	}
+	lp.locator = locator
+
+	bearing, err := keys.NewDisplay(sd, bearingBtn,
+		color.RGBA{0, 0, 0, 255},
+		color.RGBA{92, 184, 92, 255})
+	if err != nil {
+		log.Panic(err)
+	}
+	lp.bearing = bearing
+	lp.redraw = keys.Redraw(lp, &lp.active, lp.draw)
+
+	return lp
+}
+
+func (lp *locatorPage) Set(btnIndex int, state esd.BtnState) esd.Page {
+	lp.Lock()
+	defer lp.Unlock()
+
+	if btnIndex == displayBtn {
+		lp.edit(state)
+		return nil
+	}
+
+	if state == esd.BtnReleased {
+		return nil
+	}
+
+	switch btnIndex {
+	case backBtn:
+		return lp.ownParent
+	case setBtn:
+		if err := lp.il.Check(); err != nil {
+			log.Println(err)
+			keys.Flash(lp.sd, btnIndex, lp.redraw)
+			return nil
+		}
+		p, err := lp.point()
+		if err != nil {
+			log.Println(err)
+			lp.locator.ShowError()
+			return nil
+		}
+		if err := turn(lp.rotator, lp.config.Bearing(p)); err != nil {
+			log.Println(err)
+			lp.locator.ShowError()
+			return nil
+		}
+		return lp.done
+	case listBtn:
+		if len(lp.config.Entries) > 0 {
+			return newListPage(lp.sd, lp, lp.done, lp.rotator, lp.il, lp.config)
+		}
+		return nil
+	}
+
+	if _, ok := multitap.Keys[btnIndex]; ok {
+		if !lp.input.Press(btnIndex, time.Now()) {
+			keys.Flash(lp.sd, btnIndex, lp.redraw)
+			return nil
+		}
+		lp.update()
+	}
+
+	return nil
+}
+
+// edit handles the display key. A short press deletes the last
+// character, holding the key clears the locator.
+func (lp *locatorPage) edit(state esd.BtnState) {
+	lp.locator.Edit(state,
+		func() {
+			lp.input.Backspace()
+			lp.update()
+		},
+		func() {
+			lp.input.SetText("")
+			lp.update()
+		})
+}
+
+// update shows the entered locator on the display and the bearing once
+// the locator is complete. The caller must hold the lock.
+func (lp *locatorPage) update() {
+	lp.locator.SetText(lp.input.Text())
+
+	lp.bearing.SetText("")
+	if p, err := lp.point(); err == nil {
+		lp.bearing.SetText(fmt.Sprintf("%03d°", lp.config.Bearing(p)))
+	}
+
+	if lp.active {
+		lp.drawBearing()
+	}
+}
+
+// drawBearing draws the bearing of the entered locator. The caller must
+// hold the lock.
+func (lp *locatorPage) drawBearing() {
+	if err := lp.bearing.Draw(); err != nil {
+		log.Println(err)
+	}
+}
+
+// errIncomplete is returned if SET is pressed before the grid square
+// has been entered completely.
+var errIncomplete = errors.New("locator incomplete")
+
+// point returns the center of the entered grid square. The caller must
+// hold the lock.
+func (lp *locatorPage) point() (geo.Point, error) {
+	text := lp.input.Text()
+	if len(text) < locatorLen {
+		return geo.Point{}, errIncomplete
+	}
+	return geo.ParseLocator(text)
+}
+
+// turn points the rotator at bearing if it is within its range.
+func turn(r rotator.Rotator, bearing int) error {
+	cfg := r.Serialize().Config
+	az := azimuth.ForBearing(cfg, bearing)
+	if err := azimuth.Check(cfg, az); err != nil {
+		return fmt.Errorf("%s: %v", r.Name(), err)
+	}
+	return r.SetAzimuth(az)
+}
+
+func (lp *locatorPage) draw() {
+	for _, btn := range lp.btns {
+		btn.Draw()
+	}
+	lp.locator.Draw()
+	lp.drawBearing()
+}
+
+func (lp *locatorPage) Draw() {
+	lp.Lock()
+	defer lp.Unlock()
+	lp.draw()
+}
+
+func (lp *locatorPage) Parent() esd.Page {
+	lp.Lock()
+	defer lp.Unlock()
+	return lp.ownParent
+}
+
+// SetActive sets the page active. When the page is left, the entered
+// locator is discarded.
+func (lp *locatorPage) SetActive(active bool) {
+	lp.Lock()
+	defer lp.Unlock()
+	lp.active = active
+	if active {
+		return
+	}
+	lp.locator.Stop()
+	lp.input.SetText("")
+	lp.update()
+}
@@ -0,0 +1,124 @@
+// Package multitap enters text on a phone style keypad: each key carries
+// a digit and a few letters, pressing a key repeatedly selects its next
+// character.
+package multitap
+
+import (
+	"strconv"
+	"time"
+)
+
+// Timeout is the time within which a key has to be pressed again to
+// select its next character.
+const Timeout = time.Second
+
+// Key is a key of the keypad.
+type Key struct {
+	Digit   int
+	Letters string
+}
+
+// Text returns the text shown on the key, e.g. "2ABC".
+func (k Key) Text() string {
+	return strconv.Itoa(k.Digit) + k.Letters
+}
+
+// Keys maps the button indexes of the Stream Deck to the keys of
+// the keypad.
+var Keys = map[int]Key{
+	3:  {1, ""},
+	2:  {2, "ABC"},
+	1:  {3, "DEF"},
+	8:  {4, "GHI"},
+	7:  {5, "JKL"},
+	6:  {6, "MNO"},
+	13: {7, "PQRS"},
+	12: {8, "TUV"},
+	11: {9, "WXYZ"},
+	10: {0, ""},
+}
+
+// CharsFunc returns the characters which the key k can enter at the
+// position pos of the text. An empty string means that the key can't
+// be used at this position.
+type CharsFunc func(pos int, k Key) string
+
+// Input is a text which is entered on the keypad. It is not safe for
+// concurrent use.
+type Input struct {
+	chars    CharsFunc
+	maxLen   int
+	text     string
+	lastKey  int       // key which entered the last character (-1: none)
+	lastTap  time.Time // time of the last tap on lastKey
+	tapIndex int       // index of the last character within the characters of lastKey
+}
+
+// NewInput returns an empty Input which accepts up to maxLen characters.
+func NewInput(maxLen int, chars CharsFunc) *Input {
+	return &Input{
+		chars:   chars,
+		maxLen:  maxLen,
+		lastKey: -1,
+	}
+}
+
+// Press enters a character with the key at btnIndex. If the same key is
+// pressed again within Timeout, the last character is replaced with the
+// next character of the key. Press returns false if the key can't enter
+// a character.
+func (in *Input) Press(btnIndex int, now time.Time) bool {
+	k, ok := Keys[btnIndex]
+	if !ok {
+		return false
+	}
+
+	// multi-tap: replace the last character with the next one
+	if btnIndex == in.lastKey && now.Sub(in.lastTap) < Timeout {
+		chars := in.chars(len(in.text)-1, k)
+		if len(chars) > 1 {
+			in.tapIndex = (in.tapIndex + 1) % len(chars)
+			in.lastTap = now
+			in.text = in.text[:len(in.text)-1] + string(chars[in.tapIndex])
+			return true
+		}
+	}
+
+	in.lastKey = -1
+
+	if len(in.text) >= in.maxLen {
+		return false
+	}
+
+	chars := in.chars(len(in.text), k)
+	if len(chars) == 0 {
+		return false
+	}
+
+	in.lastKey = btnIndex
+	in.lastTap = now
+	in.tapIndex = 0
+	in.text += string(chars[0])
+	return true
+}
+
+// Text returns the entered text.
+func (in *Input) Text() string {
+	return in.text
+}
+
+// SetText replaces the entered text.
+func (in *Input) SetText(text string) {
+	if len(text) > in.maxLen {
+		text = text[:in.maxLen]
+	}
+	in.text = text
+	in.lastKey = -1
+}
+
+// Backspace deletes the last character.
+func (in *Input) Backspace() {
+	if len(in.text) > 0 {
+		in.SetText(in.text[:len(in.text)-1])
+	}
+}
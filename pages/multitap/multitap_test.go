@@ -0,0 +1,166 @@
+package multitap
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testChars accepts letters at the first two positions and digits after
+// them; the letters S...Z are refused.
+func testChars(pos int, k Key) string {
+	if pos < 2 {
+		letters := ""
+		for _, l := range k.Letters {
+			if l <= 'R' {
+				letters += string(l)
+			}
+		}
+		return letters
+	}
+	return strconv.Itoa(k.Digit)
+}
+
+// backspace is used as btn of a step to delete the last character.
+const backspace = -1
+
+func TestInput(t *testing.T) {
+
+	type step struct {
+		btn  int
+		at   time.Duration // since the first step
+		ok   bool
+		text string
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "taps cycle through the letters",
+			steps: []step{
+				{2, 0, true, "A"},
+				{2, time.Millisecond * 100, true, "B"},
+				{2, time.Millisecond * 200, true, "C"},
+				{2, time.Millisecond * 300, true, "A"},
+			},
+		},
+		{
+			name: "taps within the timeout of the last tap",
+			steps: []step{
+				{13, 0, true, "P"},
+				{13, time.Millisecond * 900, true, "Q"},
+				{13, time.Millisecond * 1800, true, "R"},
+				{13, time.Millisecond * 2700, true, "P"}, // S is refused
+			},
+		},
+		{
+			name: "tap after the timeout",
+			steps: []step{
+				{2, 0, true, "A"},
+				{2, Timeout, true, "AA"},
+				{2, Timeout + time.Millisecond*100, true, "AB"},
+			},
+		},
+		{
+			name: "other key",
+			steps: []step{
+				{2, 0, true, "A"},
+				{1, time.Millisecond * 100, true, "AD"},
+				{1, time.Millisecond * 200, true, "AE"},
+				{2, time.Millisecond * 300, true, "AE2"},
+			},
+		},
+		{
+			name: "single character keys don't cycle",
+			steps: []step{
+				{2, 0, true, "A"},
+				{2, Timeout, true, "AA"},
+				{10, Timeout * 2, true, "AA0"},
+				{10, Timeout*2 + time.Millisecond*100, true, "AA00"},
+			},
+		},
+		{
+			name: "max length",
+			steps: []step{
+				{8, 0, true, "G"},
+				{7, 0, true, "GJ"},
+				{3, 0, true, "GJ1"},
+				{6, 0, true, "GJ16"},
+				{6, 0, false, "GJ16"},
+				{2, 0, false, "GJ16"},
+			},
+		},
+		{
+			name: "refused characters",
+			steps: []step{
+				{11, 0, false, ""}, // WXYZ
+				{3, 0, false, ""},  // no letters
+				{12, 0, false, ""}, // TUV
+				{7, 0, true, "J"},
+				{12, time.Millisecond * 100, false, "J"},
+				{7, time.Millisecond * 200, true, "JJ"},
+			},
+		},
+		{
+			name: "no keypad key",
+			steps: []step{
+				{4, 0, false, ""},
+				{14, 0, false, ""},
+			},
+		},
+		{
+			name: "backspace",
+			steps: []step{
+				{backspace, 0, true, ""},
+				{2, 0, true, "A"},
+				{2, time.Millisecond * 100, true, "B"},
+				{backspace, time.Millisecond * 200, true, ""},
+				{2, time.Millisecond * 300, true, "A"},
+				{backspace, time.Millisecond * 400, true, ""},
+			},
+		},
+		{
+			name: "backspace ends the taps",
+			steps: []step{
+				{2, 0, true, "A"},
+				{1, time.Millisecond * 100, true, "AD"},
+				{backspace, time.Millisecond * 200, true, "A"},
+				{2, time.Millisecond * 300, true, "AA"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			in := NewInput(4, testChars)
+			start := time.Now()
+			for i, s := range tc.steps {
+				ok := true
+				if s.btn == backspace {
+					in.Backspace()
+				} else {
+					ok = in.Press(s.btn, start.Add(s.at))
+				}
+				if ok != s.ok || in.Text() != s.text {
+					t.Fatalf("step %d: got %v, %q; want %v, %q", i, ok, in.Text(), s.ok, s.text)
+				}
+			}
+		})
+	}
+}
+
+func TestInputSetText(t *testing.T) {
+
+	in := NewInput(4, testChars)
+	in.SetText("JO62QM")
+	if in.Text() != "JO62" {
+		t.Fatalf("got %q, want JO62", in.Text())
+	}
+
+	in.SetText("J")
+	if !in.Press(2, time.Now()) || in.Text() != "JA" {
+		t.Fatalf("got %q, want JA", in.Text())
+	}
+}
@@ -0,0 +1,48 @@
+// Package offline renders the buttons of devices which are not available.
+// Devices which have not been discovered yet are shown as "OFFLN" in grey,
+// devices which have gone stale (or disappeared) are shown in amber with
+// a "?" marker.
+package offline
+
+import (
+	"image/color"
+	"strings"
+
+	esd "github.com/dh1tw/streamdeck"
+	"github.com/dh1tw/streamdeck-buttons/label"
+)
+
+// Text is shown on the buttons of a device which has not been
+// discovered yet.
+const Text = "OFFLN"
+
+var (
+	greyColor  = color.RGBA{96, 96, 96, 255}
+	staleColor = color.RGBA{255, 153, 0, 255}
+)
+
+// maxLen is the maximum number of bytes a label can display.
+const maxLen = 5
+
+// NewLabel returns the placeholder of a device which has not been
+// discovered yet.
+func NewLabel(sd *esd.StreamDeck, btnIndex int) (*label.Label, error) {
+	return label.NewLabel(sd, btnIndex, label.Text(Text), label.TextColor(greyColor))
+}
+
+// NewStaleLabel returns a label showing the last known text of a
+// stale device, marked with a "?".
+func NewStaleLabel(sd *esd.StreamDeck, btnIndex int, text string) (*label.Label, error) {
+	return label.NewLabel(sd, btnIndex, label.Text(StaleText(text)), label.TextColor(staleColor))
+}
+
+// StaleText appends the "?" marker to text and shortens it if necessary.
+// The degree sign is dropped since it doesn't leave enough room.
+func StaleText(text string) string {
+	text = strings.Replace(text, "°", "", -1)
+	for len(text) > maxLen-1 {
+		r := []rune(text)
+		text = string(r[:len(r)-1])
+	}
+	return text + "?"
+}
@@ -1,101 +1,418 @@
 package presetpage
 
 import (
+	"fmt"
+	"image/color"
 	"log"
 	"sync"
 
 	"github.com/dh1tw/remoteRotator/rotator"
 	esd "github.com/dh1tw/streamdeck"
 	"github.com/dh1tw/streamdeck-buttons/label"
+	ledBtn "github.com/dh1tw/streamdeck-buttons/ledbutton"
+	"github.com/dh1tw/touchctl/azimuth"
+	"github.com/dh1tw/touchctl/hub"
+	"github.com/dh1tw/touchctl/pages/heading"
+	"github.com/dh1tw/touchctl/pages/keys"
+	locatorpage "github.com/dh1tw/touchctl/pages/locator"
+	"github.com/dh1tw/touchctl/store"
+	"github.com/dh1tw/touchctl/tx"
 )
 
+// button indexes on the preset page
+const (
+	jogBtn  = 0
+	backBtn = 4
+	lpBtn   = 7 // long path modifier
+	locBtn  = 9
+	prevBtn = 14
+	nextBtn = 10
+)
+
+// slots are the keys for the presets in the order in which they are
+// filled: the block around the LP key, the right column, and the
+// jog, paging and locator keys if they are not needed.
+var slots = []int{3, 2, 1, 8, 6, 13, 12, 11, jogBtn, 5, nextBtn, prevBtn, locBtn}
+
+// Preset is a heading which can be selected on the preset page.
+type Preset struct {
+	Name    string // max 5 chars
+	Azimuth int
+}
+
+// DefaultPresets are shown if no presets have been configured.
+var DefaultPresets = []Preset{
+	{"NW", 315},
+	{"N", 0},
+	{"NE", 45},
+	{"W", 270},
+	{"E", 90},
+	{"SW", 225},
+	{"S", 180},
+	{"SE", 135},
+}
+
 type presetPage struct {
 	sync.Mutex
 	sd         *esd.StreamDeck
 	ownParent  esd.Page
+	presets    []Preset
+	storeKeys  []string // key of each preset in the store
+	slots      []int    // keys available for presets on each page
+	page       int
 	btns       map[int]*label.Label
-	btnMapping map[int]presetValue
+	btnMapping map[int]int // key: button index, value: index of the preset
 	back       *label.Label
+	loc        *label.Label // nil: no locator entry
+	jog        *label.Label // nil: no jog entry
+	prev       *label.Label // nil: all presets fit on one page
+	next       *label.Label
+	lp         *ledBtn.LedButton
 	active     bool
 	rotator    rotator.Rotator
+	hub        *hub.Hub
+	il         *tx.Interlock
+	locConfig  *locatorpage.Config
+	newJog     func(parent esd.Page) esd.Page
+	store      *store.Store
+	pressed    int       // preset key which is held (-1: none)
+	hold       keys.Hold // preset key, held to save the current heading
+	redraw     func()
 }
 
-type presetValue struct {
-	text  string
-	value int
-}
+// NewPresetPage returns the page with the preset headings of the rotator r.
+// If there are more presets than keys, they are spread over several pages
+// which can be flipped with PREV / NEXT. While the LP modifier (key 7) is
+// on, the presets turn the rotator to the reciprocal heading (long path).
+// If locConfig is not nil, the locator page can be opened through key 9,
+// if newJog is not nil, the page it returns is opened through key 0.
+// If st is not nil, holding a preset key saves the current heading of
+// the rotator in the preset; the saved presets replace the given ones.
+func NewPresetPage(sd *esd.StreamDeck, parent esd.Page, r rotator.Rotator, h *hub.Hub, il *tx.Interlock, presets []Preset, locConfig *locatorpage.Config, newJog func(parent esd.Page) esd.Page, st *store.Store) esd.Page {
 
-func NewPresetPage(sd *esd.StreamDeck, parent esd.Page, r rotator.Rotator) esd.Page {
+	if len(presets) == 0 {
+		presets = DefaultPresets
+	}
 
 	pp := &presetPage{
 		sd:        sd,
 		ownParent: parent,
+		presets:   append([]Preset{}, presets...),
 		rotator:   r,
-		btns:      make(map[int]*label.Label),
-		btnMapping: map[int]presetValue{
-			3:  presetValue{"NW", 315},
-			2:  presetValue{"N", 0},
-			1:  presetValue{"NE", 45},
-			8:  presetValue{"W", 270},
-			6:  presetValue{"E", 90},
-			13: presetValue{"SW", 225},
-			12: presetValue{"S", 180},
-			11: presetValue{"SE", 135},
-			0:  presetValue{"NA", 320},
-			5:  presetValue{"KH6", 350},
-			10: presetValue{"VK", 75},
-		},
-	}
-
-	for pos, v := range pp.btnMapping {
-		l, err := label.NewLabel(sd, pos, label.Text(v.text))
+		hub:       h,
+		il:        il,
+		locConfig: locConfig,
+		newJog:    newJog,
+		store:     st,
+		pressed:   -1,
+	}
+
+	if st != nil {
+		pp.storeKeys = storeKeys(pp.presets)
+		saved := st.Presets(r.Name())
+		for i, key := range pp.storeKeys {
+			if p, ok := saved[key]; ok {
+				pp.presets[i] = Preset{Name: p.Name, Azimuth: p.Azimuth}
+			}
+		}
+	}
+
+	for _, pos := range slots {
+		if pos == locBtn && locConfig != nil {
+			continue
+		}
+		if pos == jogBtn && newJog != nil {
+			continue
+		}
+		pp.slots = append(pp.slots, pos)
+	}
+
+	if len(presets) > len(pp.slots) {
+		pp.slots = removeBtns(pp.slots, prevBtn, nextBtn)
+		prev, err := label.NewLabel(sd, prevBtn, label.Text("PREV"))
 		if err != nil {
 			log.Panic(err)
 		}
-		pp.btns[pos] = l
+		pp.prev = prev
+		next, err := label.NewLabel(sd, nextBtn, label.Text("NEXT"))
+		if err != nil {
+			log.Panic(err)
+		}
+		pp.next = next
 	}
 
-	back, err := label.NewLabel(sd, 4, label.Text("BACK"))
+	back, err := label.NewLabel(sd, backBtn, label.Text("BACK"))
 	if err != nil {
 		log.Panic(err)
 	}
 	pp.back = back
 
+	lp, err := ledBtn.NewLedButton(sd, lpBtn, ledBtn.Text("LP"), ledBtn.LedColor(ledBtn.LEDYellow))
+	if err != nil {
+		log.Panic(err)
+	}
+	pp.lp = lp
+
+	if locConfig != nil {
+		loc, err := label.NewLabel(sd, locBtn, label.Text("LOC"))
+		if err != nil {
+			log.Panic(err)
+		}
+		pp.loc = loc
+	}
+
+	if newJog != nil {
+		jog, err := label.NewLabel(sd, jogBtn, label.Text("JOG"))
+		if err != nil {
+			log.Panic(err)
+		}
+		pp.jog = jog
+	}
+
+	pp.setPage(0)
+	pp.redraw = keys.Redraw(pp, &pp.active, pp.draw)
+
 	return pp
 }
 
+// storeKeys returns the keys under which the presets are saved in the
+// store: their configured names. If a name occurs more than once, e.g.
+// a preset and a prefix, the repetitions are numbered ("W", "W#2").
+func storeKeys(presets []Preset) []string {
+	count := make(map[string]int)
+	res := make([]string, 0, len(presets))
+	for _, p := range presets {
+		count[p.Name]++
+		key := p.Name
+		if n := count[p.Name]; n > 1 {
+			key = fmt.Sprintf("%s#%d", p.Name, n)
+		}
+		res = append(res, key)
+	}
+	return res
+}
+
+// removeBtns returns btns without the buttons in remove.
+func removeBtns(btns []int, remove ...int) []int {
+	res := []int{}
+	for _, b := range btns {
+		keep := true
+		for _, r := range remove {
+			if b == r {
+				keep = false
+			}
+		}
+		if keep {
+			res = append(res, b)
+		}
+	}
+	return res
+}
+
+// numPages returns the number of pages needed for the presets.
+func (pp *presetPage) numPages() int {
+	return (len(pp.presets) + len(pp.slots) - 1) / len(pp.slots)
+}
+
+// setPage places the presets of page n on the keys. The caller must
+// hold the lock.
+func (pp *presetPage) setPage(n int) {
+	pp.page = n
+	pp.btns = make(map[int]*label.Label)
+	pp.btnMapping = make(map[int]int)
+
+	first := n * len(pp.slots)
+	for i, pos := range pp.slots {
+		if first+i >= len(pp.presets) {
+			break
+		}
+		p := pp.presets[first+i]
+		l, err := label.NewLabel(pp.sd, pos, label.Text(p.Name))
+		if err != nil {
+			log.Panic(err)
+		}
+		pp.btns[pos] = l
+		pp.btnMapping[pos] = first + i
+	}
+}
+
 func (pp *presetPage) Set(btnIndex int, state esd.BtnState) esd.Page {
 	pp.Lock()
 	defer pp.Unlock()
 
 	if state == esd.BtnReleased {
+		if btnIndex == pp.pressed {
+			return pp.release(btnIndex)
+		}
 		return nil
 	}
 
 	switch btnIndex {
-	case 4:
+	case backBtn:
 		return pp.parent()
+	case lpBtn:
+		pp.lp.SetState(!pp.lp.State())
+		pp.lp.Draw()
+		return nil
+	case prevBtn, nextBtn:
+		if pp.prev != nil {
+			n := pp.numPages()
+			if btnIndex == prevBtn {
+				pp.setPage((pp.page + n - 1) % n)
+			} else {
+				pp.setPage((pp.page + 1) % n)
+			}
+			pp.draw()
+			return nil
+		}
+	case locBtn:
+		if pp.locConfig != nil {
+			return locatorpage.NewLocatorPage(pp.sd, pp, pp.parent().Parent(), pp.rotator, pp.il, pp.locConfig)
+		}
+	case jogBtn:
+		if pp.newJog != nil {
+			return pp.newJog(pp)
+		}
 	}
 
-	v, ok := pp.btnMapping[btnIndex]
+	index, ok := pp.btnMapping[btnIndex]
 	if !ok {
 		return nil
 	}
 
-	err := pp.rotator.SetAzimuth(v.value)
+	if pp.store == nil {
+		return pp.apply(btnIndex, pp.presets[index])
+	}
+
+	// the preset is applied when the key is released, unless it
+	// has been held to save the current heading
+	pp.pressed = btnIndex
+	pp.hold.Press(func() {
+		pp.Lock()
+		defer pp.Unlock()
+		if pp.active && pp.pressed == btnIndex {
+			pp.highlight(btnIndex, pp.presets[index])
+		}
+	})
+
+	return nil
+}
+
+// release handles the release of the preset key which has been pressed.
+// A short press applies the preset, a long press opens the page to save
+// the current heading in the preset. The caller must hold the lock.
+func (pp *presetPage) release(btnIndex int) esd.Page {
+	long := !pp.hold.Release()
+	pp.pressed = -1
+
+	index := pp.btnMapping[btnIndex]
+	if !long {
+		return pp.apply(btnIndex, pp.presets[index])
+	}
+
+	return newSavePage(pp.sd, pp, index, pp.presets[index].Name, pp.rotator.Azimuth())
+}
+
+// apply turns the rotator to the preset v. If the heading is out of the
+// range of the rotator or the rotator can't be turned, the key is
+// flashed and the page stays open. The caller must hold the lock.
+func (pp *presetPage) apply(btnIndex int, v Preset) esd.Page {
+	if err := pp.il.Check(); err != nil {
+		log.Println(err)
+		keys.Flash(pp.sd, btnIndex, pp.redraw)
+		return nil
+	}
+
+	longPath := pp.lp.State()
+	az, err := target(pp.rotator.Serialize().Config, v.Azimuth, longPath)
 	if err != nil {
+		log.Printf("%s: %v", pp.rotator.Name(), err)
+		keys.Flash(pp.sd, btnIndex, pp.redraw)
+		return nil
+	}
+
+	pp.hub.SetLongPath(pp.rotator.Name(), az, longPath)
+	if err := pp.rotator.SetAzimuth(az); err != nil {
 		log.Println(err)
+		pp.hub.SetLongPath(pp.rotator.Name(), az, false)
+		keys.Flash(pp.sd, btnIndex, pp.redraw)
+		return nil
 	}
 
 	pp.parent().Parent().SetActive(true)
 	return pp.parent().Parent()
 }
 
+// target returns the azimuth the rotator with the configuration cfg has
+// to be turned to for the preset heading az, or for its reciprocal if
+// longPath is set. A heading which is out of range is tried as bearing,
+// e.g. 90° on a rotator turning from 180° to 540° becomes 450°.
+func target(cfg rotator.Config, az int, longPath bool) (int, error) {
+	if longPath {
+		az = heading.Reciprocal(az)
+	}
+	if azimuth.Check(cfg, az) != nil {
+		az = azimuth.ForBearing(cfg, az)
+	}
+	if err := azimuth.Check(cfg, az); err != nil {
+		return 0, err
+	}
+	return az, nil
+}
+
+// highlight shows that the preset key has been held long enough to
+// save the current heading. The caller must hold the lock.
+func (pp *presetPage) highlight(btnIndex int, p Preset) {
+	l, err := label.NewLabel(pp.sd, btnIndex,
+		label.Text(p.Name),
+		label.BgColor(color.RGBA{91, 192, 222, 255}),
+		label.TextColor(color.RGBA{0, 0, 0, 255}))
+	if err != nil {
+		log.Panic(err)
+	}
+	l.Draw()
+}
+
+// save replaces the preset at index and writes it to the state file.
+// The preset is kept until the next restart even if it couldn't be
+// written.
+func (pp *presetPage) save(index int, p Preset) error {
+	pp.Lock()
+	defer pp.Unlock()
+
+	pp.presets[index] = p
+	pp.setPage(pp.page)
+
+	return pp.store.SavePreset(pp.rotator.Name(), pp.storeKeys[index], store.Preset{
+		Name:    p.Name,
+		Azimuth: p.Azimuth,
+	})
+}
+
 func (pp *presetPage) draw() {
-	for _, btn := range pp.btns {
+	for _, pos := range pp.slots {
+		btn, ok := pp.btns[pos]
+		if !ok {
+			// unused key on the last page
+			if err := pp.sd.FillColor(pos, 0, 0, 0); err != nil {
+				log.Println(err)
+			}
+			continue
+		}
 		btn.Draw()
 	}
+	if pp.prev != nil {
+		pp.prev.Draw()
+		pp.next.Draw()
+	}
 	pp.back.Draw()
+	pp.lp.Draw()
+	if pp.loc != nil {
+		pp.loc.Draw()
+	}
+	if pp.jog != nil {
+		pp.jog.Draw()
+	}
 }
 
 func (pp *presetPage) Draw() {
@@ -118,4 +435,8 @@ func (pp *presetPage) SetActive(active bool) {
 	pp.Lock()
 	defer pp.Unlock()
 	pp.active = active
+	if !active {
+		pp.hold.Stop()
+		pp.pressed = -1
+	}
 }
@@ -0,0 +1,74 @@
+package presetpage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+func TestStoreKeys(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		presets []Preset
+		want    []string
+	}{
+		{"none", nil, []string{}},
+		{"unique", []Preset{{"N", 0}, {"E", 90}}, []string{"N", "E"}},
+		{"reordered", []Preset{{"E", 90}, {"N", 0}}, []string{"E", "N"}},
+		{"preset and prefix", []Preset{{"W", 270}, {"JA", 38}, {"W", 320}, {"W", 300}}, []string{"W", "JA", "W#2", "W#3"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := storeKeys(tc.presets); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTarget(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		min, max int
+		az       int
+		longPath bool
+		want     int // -1: out of range
+	}{
+		{"full circle", 0, 359, 90, false, 90},
+		{"full circle, long path", 0, 359, 90, true, 270},
+		{"long path across north", 0, 359, 270, true, 90},
+		{"unknown range", 0, 0, 45, true, 225},
+		{"overlap", 0, 450, 400, false, 400},
+		{"overlap, long path", 0, 450, 400, true, 220},
+		{"starts south", 180, 540, 90, false, 450},
+		{"starts south, long path", 180, 540, 225, true, 405},
+		{"out of range", 90, 270, 45, false, -1},
+		{"reciprocal out of range", 90, 270, 180, true, -1},
+		{"reciprocal in range", 90, 270, 0, true, 180},
+		{"wraps around north", 270, 90, 200, true, 20},
+		{"wraps around north, out of range", 270, 90, 0, true, -1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := rotator.Config{AzimuthMin: tc.min, AzimuthMax: tc.max}
+			az, err := target(cfg, tc.az, tc.longPath)
+			if tc.want < 0 {
+				if err == nil {
+					t.Fatalf("expected an error, got %d°", az)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if az != tc.want {
+				t.Fatalf("got %d°, want %d°", az, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package presetpage
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	esd "github.com/dh1tw/streamdeck"
+	"github.com/dh1tw/streamdeck-buttons/label"
+	"github.com/dh1tw/touchctl/pages/keys"
+	"github.com/dh1tw/touchctl/pages/multitap"
+)
+
+// maxNameLen is the number of characters which fit on a key.
+const maxNameLen = 5
+
+// button indexes on the save page
+const (
+	nameBtn    = 0
+	saveBtn    = 5
+	headingBtn = 14
+)
+
+// nameChars returns the characters which the key k can enter in the
+// name of a preset: its letters, followed by its digit.
+func nameChars(pos int, k multitap.Key) string {
+	return k.Letters + strconv.Itoa(k.Digit)
+}
+
+// savePage saves the current heading of the rotator in a preset. The
+// name of the preset is edited on the multi-tap keypad.
+type savePage struct {
+	sync.Mutex
+	sd      *esd.StreamDeck
+	presets *presetPage
+	index   int // index of the preset
+	azimuth int
+	btns    map[int]*label.Label
+	name    *keys.Entry
+	input   *multitap.Input
+	active  bool
+	redraw  func()
+}
+
+func newSavePage(sd *esd.StreamDeck, pp *presetPage, index int, name string, azimuth int) esd.Page {
+
+	sp := &savePage{
+		sd:      sd,
+		presets: pp,
+		index:   index,
+		azimuth: azimuth,
+		btns:    make(map[int]*label.Label),
+		input:   multitap.NewInput(maxNameLen, nameChars),
+	}
+
+	sp.input.SetText(name)
+
+	for pos, k := range multitap.Keys {
+		l, err := label.NewLabel(sd, pos, label.Text(k.Text()))
+		if err != nil {
+			log.Panic(err)
+		}
+		sp.btns[pos] = l
+	}
+
+	texts := map[int]string{
+		backBtn: "BACK",
+		saveBtn: "SAVE",
+	}
+	for pos, text := range texts {
+		l, err := label.NewLabel(sd, pos, label.Text(text))
+		if err != nil {
+			log.Panic(err)
+		}
+		sp.btns[pos] = l
+	}
+
+	heading, err := label.NewLabel(sd, headingBtn,
+		label.Text(fmt.Sprintf("%03d°", azimuth)),
+		label.TextColor(color.RGBA{92, 184, 92, 255}))
+	if err != nil {
+		log.Panic(err)
+	}
+	sp.btns[headingBtn] = heading
+
+	entry, err := keys.NewEntry(sd, nameBtn, sp, &sp.active)
+	if err != nil {
+		log.Panic(err)
+	}
+	entry.SetText(sp.input.Text())
+	sp.name = entry
+	sp.redraw = keys.Redraw(sp, &sp.active, sp.draw)
+
+	return sp
+}
+
+func (sp *savePage) Set(btnIndex int, state esd.BtnState) esd.Page {
+	sp.Lock()
+	defer sp.Unlock()
+
+	if btnIndex == nameBtn {
+		sp.edit(state)
+		return nil
+	}
+
+	if state == esd.BtnReleased {
+		return nil
+	}
+
+	switch btnIndex {
+	case backBtn:
+		return sp.presets
+	case saveBtn:
+		name := sp.input.Text()
+		if len(name) == 0 {
+			name = fmt.Sprintf("%03d", sp.azimuth)
+		}
+		err := sp.presets.save(sp.index, Preset{Name: name, Azimuth: sp.azimuth})
+		if err != nil {
+			log.Printf("preset %s not saved: %v", name, err)
+			sp.name.ShowError()
+			return nil
+		}
+		return sp.presets
+	}
+
+	if _, ok := multitap.Keys[btnIndex]; ok {
+		if !sp.input.Press(btnIndex, time.Now()) {
+			keys.Flash(sp.sd, btnIndex, sp.redraw)
+			return nil
+		}
+		sp.update()
+	}
+
+	return nil
+}
+
+// edit handles the display key. A short press deletes the last
+// character, holding the key clears the name.
+func (sp *savePage) edit(state esd.BtnState) {
+	sp.name.Edit(state,
+		func() {
+			sp.input.Backspace()
+			sp.update()
+		},
+		func() {
+			sp.input.SetText("")
+			sp.update()
+		})
+}
+
+// update shows the entered name on the display. The caller must hold
+// the lock.
+func (sp *savePage) update() {
+	sp.name.SetText(sp.input.Text())
+}
+
+func (sp *savePage) draw() {
+	for _, btn := range sp.btns {
+		btn.Draw()
+	}
+	sp.name.Draw()
+}
+
+func (sp *savePage) Draw() {
+	sp.Lock()
+	defer sp.Unlock()
+	sp.draw()
+}
+
+func (sp *savePage) Parent() esd.Page {
+	return sp.presets
+}
+
+func (sp *savePage) SetActive(active bool) {
+	sp.Lock()
+	defer sp.Unlock()
+	sp.active = active
+	if !active {
+		sp.name.Stop()
+	}
+}
@@ -0,0 +1,305 @@
+package rotatorpage
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	esd "github.com/dh1tw/streamdeck"
+	"github.com/dh1tw/streamdeck-buttons/label"
+	"github.com/dh1tw/touchctl/azimuth"
+	"github.com/dh1tw/touchctl/hub"
+	"github.com/dh1tw/touchctl/pages/heading"
+	"github.com/dh1tw/touchctl/pages/keys"
+	"github.com/dh1tw/touchctl/tx"
+)
+
+// JogConfig configures the CW / CCW keys of the jog page.
+type JogConfig struct {
+	Step   int           // degrees per step (0: defaultJogStep)
+	Repeat time.Duration // interval between two steps while a key is held (0: defaultJogRepeat)
+}
+
+const (
+	defaultJogStep   = 5
+	defaultJogRepeat = time.Millisecond * 300
+)
+
+func (c JogConfig) step() int {
+	if c.Step <= 0 {
+		return defaultJogStep
+	}
+	return c.Step
+}
+
+func (c JogConfig) repeat() time.Duration {
+	if c.Repeat <= 0 {
+		return defaultJogRepeat
+	}
+	return c.Repeat
+}
+
+// button indexes on the jog page
+const (
+	jogBack    = 4
+	jogHeading = 2
+	jogCCW     = 8
+	jogStop    = 7
+	jogCW      = 6
+)
+
+// jogPage turns the rotator in small steps as long as the CW or CCW key
+// is held.
+type jogPage struct {
+	sync.Mutex
+	sd        *esd.StreamDeck
+	ownParent esd.Page
+	rotator   rotator.Rotator
+	hub       *hub.Hub
+	il        *tx.Interlock
+	config    JogConfig
+	heading   *heading.Widget
+	btns      map[int]*label.Label
+	sub       *hub.Subscription
+	done      chan struct{} // closed to stop jogging; nil while not jogging
+	target    int
+	active    bool
+	redraw    func()
+	ticker    func(time.Duration) (<-chan time.Time, func()) // ticks and stop func; replaced by the tests
+}
+
+func newTicker(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
+
+func newJogPage(sd *esd.StreamDeck, parent esd.Page, r rotator.Rotator, h *hub.Hub, il *tx.Interlock, config JogConfig) esd.Page {
+
+	jp := &jogPage{
+		sd:        sd,
+		ownParent: parent,
+		rotator:   r,
+		hub:       h,
+		il:        il,
+		config:    config,
+		heading:   heading.NewWidget(rotator.Heading{}),
+		btns:      make(map[int]*label.Label),
+		ticker:    newTicker,
+	}
+
+	for pos, text := range map[int]string{
+		jogBack: "BACK",
+		jogCCW:  "CCW",
+		jogStop: "STOP",
+		jogCW:   "CW",
+	} {
+		l, err := label.NewLabel(sd, pos, label.Text(text))
+		if err != nil {
+			log.Panic(err)
+		}
+		jp.btns[pos] = l
+	}
+	jp.redraw = keys.Redraw(jp, &jp.active, jp.draw)
+
+	return jp
+}
+
+func (jp *jogPage) Set(btnIndex int, state esd.BtnState) esd.Page {
+	jp.Lock()
+	defer jp.Unlock()
+
+	if state == esd.BtnReleased {
+		if btnIndex == jogCW || btnIndex == jogCCW {
+			jp.stopJog()
+		}
+		return nil
+	}
+
+	switch btnIndex {
+	case jogBack:
+		jp.stopJog()
+		return jp.ownParent
+	case jogStop:
+		jp.stopJog()
+		if err := jp.rotator.Stop(); err != nil {
+			log.Println(err)
+		}
+	case jogCW:
+		jp.startJog(btnIndex, 1)
+	case jogCCW:
+		jp.startJog(btnIndex, -1)
+	}
+
+	return nil
+}
+
+// startJog turns the rotator one step in the given direction (1: CW,
+// -1: CCW) and keeps on stepping until stopJog is called. The caller
+// must hold the lock.
+func (jp *jogPage) startJog(btnIndex int, dir int) {
+	jp.stopJog()
+
+	jp.target = jp.rotator.Azimuth()
+	if !jp.step(btnIndex, dir) {
+		return
+	}
+
+	done := make(chan struct{})
+	jp.done = done
+	ticks, stop := jp.ticker(jp.config.repeat())
+
+	go func() {
+		defer stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticks:
+				jp.Lock()
+				select {
+				case <-done:
+					jp.Unlock()
+					return
+				default:
+				}
+				if !jp.step(btnIndex, dir) {
+					jp.stopJog()
+				}
+				jp.Unlock()
+			}
+		}
+	}()
+}
+
+// stopJog stops jogging. The rotator continues to the last step.
+// The caller must hold the lock.
+func (jp *jogPage) stopJog() {
+	if jp.done == nil {
+		return
+	}
+	close(jp.done)
+	jp.done = nil
+}
+
+// step moves the target one step further. The target is limited to the
+// range of the rotator; once the limit has been reached, the key is
+// flashed and step returns false to stop jogging. It also returns false
+// if the rotator could not be turned. The caller must hold the lock.
+func (jp *jogPage) step(btnIndex int, dir int) bool {
+	if err := jp.il.Check(); err != nil {
+		log.Println(err)
+		keys.Flash(jp.sd, btnIndex, jp.redraw)
+		return false
+	}
+
+	target, atLimit := jogTarget(jp.rotator.Serialize().Config, jp.target, dir*jp.config.step())
+	if target != jp.target {
+		jp.target = target
+		if err := jp.rotator.SetAzimuth(target); err != nil {
+			log.Println(err)
+			return false
+		}
+	}
+
+	if atLimit {
+		log.Printf("%s: limit of %d° reached\n", jp.rotator.Name(), target)
+		keys.Flash(jp.sd, btnIndex, jp.redraw)
+		return false
+	}
+	return true
+}
+
+// jogTarget returns the target after turning by delta degrees from
+// target. Rotators with overlap are not wrapped around north, only
+// rotators whose range includes north (min > max) are. If the new target
+// is out of range, the limit in the direction of delta is returned and
+// atLimit is true.
+func jogTarget(cfg rotator.Config, target int, delta int) (int, bool) {
+	t := target + delta
+	if cfg.AzimuthMin > cfg.AzimuthMax {
+		// the range includes north, e.g. 270...90
+		t = (t%360 + 360) % 360
+	}
+
+	if azimuth.Check(cfg, t) == nil {
+		return t, false
+	}
+
+	if delta < 0 {
+		return cfg.AzimuthMin, true
+	}
+	if cfg.AzimuthMin == 0 && cfg.AzimuthMax == 0 {
+		return 359, true // range unknown
+	}
+	return cfg.AzimuthMax, true
+}
+
+// eventHandler updates the heading of the rotator.
+func (jp *jogPage) eventHandler(ev hub.Event) {
+	if ev.Rotator == nil || ev.Heading == nil || ev.Rotator.Name() != jp.rotator.Name() {
+		return
+	}
+
+	jp.Lock()
+	defer jp.Unlock()
+	jp.heading.Update(*ev.Heading)
+	if jp.active {
+		jp.drawHeading()
+	}
+}
+
+func (jp *jogPage) drawHeading() {
+	if err := jp.heading.Draw(jp.sd, jogHeading); err != nil {
+		log.Println(err)
+	}
+}
+
+func (jp *jogPage) draw() {
+	for _, btn := range jp.btns {
+		btn.Draw()
+	}
+	jp.drawHeading()
+}
+
+func (jp *jogPage) Draw() {
+	jp.Lock()
+	defer jp.Unlock()
+	jp.draw()
+}
+
+func (jp *jogPage) Parent() esd.Page {
+	jp.Lock()
+	defer jp.Unlock()
+	return jp.ownParent
+}
+
+// SetActive subscribes to the heading updates of the rotator while the
+// page is shown. Jogging stops when the page is left.
+func (jp *jogPage) SetActive(active bool) {
+	jp.Lock()
+	jp.active = active
+	sub := jp.sub
+	jp.sub = nil
+	if active {
+		jp.heading.Update(rotator.Heading{
+			Azimuth:  jp.rotator.Azimuth(),
+			AzPreset: jp.rotator.AzPreset(),
+		})
+	} else {
+		jp.stopJog()
+	}
+	jp.Unlock()
+
+	// (un)subscribe without holding the lock, since the event handler
+	// might be waiting for it
+	if sub != nil {
+		jp.hub.Unsubscribe(sub)
+	}
+	if active && jp.hub != nil {
+		sub := jp.hub.Subscribe(jp.eventHandler, hub.UpdateHeading)
+		jp.Lock()
+		jp.sub = sub
+		jp.Unlock()
+	}
+}
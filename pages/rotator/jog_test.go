@@ -0,0 +1,162 @@
+package rotatorpage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	esd "github.com/dh1tw/streamdeck"
+	"github.com/dh1tw/touchctl/internal/rotatortest"
+	"github.com/dh1tw/touchctl/tx"
+)
+
+func TestJogTarget(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		min, max int
+		target   int
+		delta    int
+		want     int
+		atLimit  bool
+	}{
+		{"cw", 0, 359, 100, 5, 105, false},
+		{"ccw", 0, 359, 100, -5, 95, false},
+		{"cw at north", 0, 359, 357, 5, 359, true},
+		{"ccw at north", 0, 359, 2, -5, 0, true},
+		{"ccw at limit", 0, 359, 0, -5, 0, true},
+		{"unknown range", 0, 0, 357, 5, 359, true},
+		{"overlap", 0, 450, 358, 5, 363, false},
+		{"overlap at limit", 0, 450, 448, 5, 450, true},
+		{"overlap ccw", 0, 450, 363, -5, 358, false},
+		{"offset range", 180, 540, 182, -5, 180, true},
+		{"offset range overlap", 180, 540, 358, 5, 363, false},
+		{"range across north cw", 270, 90, 358, 5, 3, false},
+		{"range across north ccw", 270, 90, 3, -5, 358, false},
+		{"range across north cw limit", 270, 90, 88, 5, 90, true},
+		{"range across north ccw limit", 270, 90, 272, -5, 270, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := rotator.Config{HasAzimuth: true, AzimuthMin: tc.min, AzimuthMax: tc.max}
+			got, atLimit := jogTarget(cfg, tc.target, tc.delta)
+			if got != tc.want || atLimit != tc.atLimit {
+				t.Fatalf("got %d (limit: %v), want %d (limit: %v)", got, atLimit, tc.want, tc.atLimit)
+			}
+		})
+	}
+}
+
+// fakeTicker replaces the ticker of a jog page; the ticks are sent by
+// the test.
+type fakeTicker struct {
+	interval time.Duration
+	ticks    chan time.Time
+	stopped  chan struct{}
+}
+
+func (ft *fakeTicker) start(d time.Duration) (<-chan time.Time, func()) {
+	ft.interval = d
+	ft.ticks = make(chan time.Time)
+	ft.stopped = make(chan struct{})
+	return ft.ticks, func() { close(ft.stopped) }
+}
+
+// tick sends a tick and returns false if the page doesn't wait for it.
+func (ft *fakeTicker) tick() bool {
+	select {
+	case ft.ticks <- time.Now():
+		return true
+	case <-time.After(time.Millisecond * 100):
+		return false
+	}
+}
+
+// waitSets waits until the rotator has been turned n times.
+func waitSets(t *testing.T, r *rotatortest.Fake, n int) []int {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for len(r.Sets()) < n && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	return r.Sets()
+}
+
+func TestJog(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		config   JogConfig
+		btn      int
+		interval time.Duration
+		want     []int
+	}{
+		{"cw, default step and repeat", JogConfig{}, jogCW, defaultJogRepeat, []int{105, 110, 115}},
+		{"ccw, configured step and repeat", JogConfig{Step: 10, Repeat: time.Millisecond * 150}, jogCCW, time.Millisecond * 150, []int{90, 80, 70}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := rotatortest.New("Yagi", rotator.Config{HasAzimuth: true, AzimuthMin: 0, AzimuthMax: 359})
+			r.SetHeading(100)
+			ft := &fakeTicker{}
+			jp := newJogPage(&esd.StreamDeck{}, nil, r, nil, tx.NewInterlock(), tc.config).(*jogPage)
+			jp.ticker = ft.start
+
+			// the first step is taken when the key is pressed
+			jp.Set(tc.btn, esd.BtnPressed)
+			if got := r.Sets(); !reflect.DeepEqual(got, tc.want[:1]) {
+				t.Fatalf("after pressing the key: got %v, want %v", got, tc.want[:1])
+			}
+			if ft.interval != tc.interval {
+				t.Fatalf("got repeat interval %v, want %v", ft.interval, tc.interval)
+			}
+
+			// one step per tick while the key is held
+			for i := 1; i < len(tc.want); i++ {
+				if !ft.tick() {
+					t.Fatal("jogging stopped while the key is held")
+				}
+				waitSets(t, r, i+1)
+			}
+			if got := r.Sets(); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+
+			// releasing the key stops stepping
+			jp.Set(tc.btn, esd.BtnReleased)
+			select {
+			case <-ft.stopped:
+			case <-time.After(time.Second):
+				t.Fatal("ticker not stopped after releasing the key")
+			}
+			if ft.tick() {
+				t.Fatal("still jogging after releasing the key")
+			}
+			if got := r.Sets(); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("after releasing the key: got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestJogStop(t *testing.T) {
+
+	r := rotatortest.New("Yagi", rotator.Config{HasAzimuth: true, AzimuthMin: 0, AzimuthMax: 359})
+	ft := &fakeTicker{}
+	jp := newJogPage(&esd.StreamDeck{}, nil, r, nil, tx.NewInterlock(), JogConfig{}).(*jogPage)
+	jp.ticker = ft.start
+
+	jp.Set(jogCW, esd.BtnPressed)
+	jp.Set(jogStop, esd.BtnPressed)
+	select {
+	case <-ft.stopped:
+	case <-time.After(time.Second):
+		t.Fatal("ticker not stopped by STOP")
+	}
+	if r.Stops() != 1 {
+		t.Fatalf("got %d stops, want 1", r.Stops())
+	}
+}
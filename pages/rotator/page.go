@@ -1,7 +1,7 @@
 package rotatorpage
 
 import (
-	"image/color"
+	"fmt"
 	"log"
 	"strconv"
 	"sync"
@@ -9,25 +9,76 @@ import (
 	"github.com/dh1tw/remoteRotator/rotator"
 	esd "github.com/dh1tw/streamdeck"
 	"github.com/dh1tw/streamdeck-buttons/label"
+	ledBtn "github.com/dh1tw/streamdeck-buttons/ledbutton"
+	"github.com/dh1tw/touchctl/azimuth"
+	"github.com/dh1tw/touchctl/hub"
+	"github.com/dh1tw/touchctl/pages/heading"
+	"github.com/dh1tw/touchctl/pages/keys"
+	locatorpage "github.com/dh1tw/touchctl/pages/locator"
 	presetpage "github.com/dh1tw/touchctl/pages/preset"
+	"github.com/dh1tw/touchctl/store"
+	"github.com/dh1tw/touchctl/tx"
 )
 
+// maxDigits is the maximum length of the entered heading.
+const maxDigits = 3
+
+// newPosBtn is the key which shows the entered heading.
+const newPosBtn = 0
+
+// setBtn is the key which turns the rotator to the entered heading. While
+// no heading has been entered, it stops the rotator.
+const setBtn = 5
+
+// lpBtn is the long path modifier.
+const lpBtn = 14
+
 type rotatorPage struct {
 	sync.Mutex
 	sd            *esd.StreamDeck
 	ownParent     esd.Page
 	numPad        map[int]*label.Label
-	newPos        *label.Label
+	newPos        *keys.Entry
 	back          *label.Label
 	set           *label.Label
+	stop          *label.Label // shown on setBtn while no heading has been entered
+	lp            *ledBtn.LedButton
 	preset        *label.Label
 	newPosText    string
 	keyPadMapping map[int]int
 	rotator       rotator.Rotator
+	hub           *hub.Hub
+	il            *tx.Interlock
+	config        Config
 	active        bool
+	redraw        func()
+}
+
+// Config contains the station specific settings of the rotator page.
+type Config struct {
+	Jog      JogConfig
+	Presets  []presetpage.Preset // empty: presetpage.DefaultPresets
+	Prefixes []presetpage.Preset // DXCC prefixes, appended to the presets
+	Locator  *locatorpage.Config // nil: no locator entry
+	Store    *store.Store        // nil: presets can't be saved
+}
+
+// presets returns the presets followed by the prefixes. The prefixes are
+// kept separately, so that they are also added to the presets which
+// replace the station presets for a single rotator.
+func (c Config) presets() []presetpage.Preset {
+	res := append([]presetpage.Preset{}, c.Presets...)
+	return append(res, c.Prefixes...)
 }
 
-func NewRotatorPage(sd *esd.StreamDeck, parent esd.Page, r rotator.Rotator) esd.Page {
+// NewRotatorPage returns the page to turn the rotator r. The heading is
+// entered on a keypad; pressing the display key deletes the last digit,
+// holding it clears the entry. While the LP modifier (key 14) is on, SET
+// turns the rotator to the reciprocal heading (long path). While no heading
+// has been entered, the SET key shows STOP and stops the rotator. The jog page
+// (key 0 on the preset page) turns the rotator step by step as long as
+// CW / CCW is held.
+func NewRotatorPage(sd *esd.StreamDeck, parent esd.Page, r rotator.Rotator, h *hub.Hub, il *tx.Interlock, config Config) esd.Page {
 
 	sp := &rotatorPage{
 		sd:        sd,
@@ -46,11 +97,12 @@ func NewRotatorPage(sd *esd.StreamDeck, parent esd.Page, r rotator.Rotator) esd.
 			11: 9,
 		},
 		rotator: r,
+		hub:     h,
+		il:      il,
+		config:  config,
 	}
 
-	newPos, err := label.NewLabel(sd, 0,
-		label.BgColor(color.RGBA{0, 255, 0, 255}),
-		label.TextColor(color.RGBA{0, 0, 0, 255}))
+	newPos, err := keys.NewEntry(sd, newPosBtn, sp, &sp.active)
 	if err != nil {
 		log.Panic(err)
 	}
@@ -68,12 +120,24 @@ func NewRotatorPage(sd *esd.StreamDeck, parent esd.Page, r rotator.Rotator) esd.
 		sp.numPad[pos] = l
 	}
 
-	set, err := label.NewLabel(sd, 5, label.Text("SET"))
+	set, err := label.NewLabel(sd, setBtn, label.Text("SET"))
 	if err != nil {
 		log.Panic(err)
 	}
 	sp.set = set
 
+	stop, err := label.NewLabel(sd, setBtn, label.Text("STOP"))
+	if err != nil {
+		log.Panic(err)
+	}
+	sp.stop = stop
+
+	lp, err := ledBtn.NewLedButton(sd, lpBtn, ledBtn.Text("LP"), ledBtn.LedColor(ledBtn.LEDYellow))
+	if err != nil {
+		log.Panic(err)
+	}
+	sp.lp = lp
+
 	ret, err := label.NewLabel(sd, 4, label.Text("BACK"))
 	if err != nil {
 		log.Panic(err)
@@ -85,6 +149,7 @@ func NewRotatorPage(sd *esd.StreamDeck, parent esd.Page, r rotator.Rotator) esd.
 		log.Panic(err)
 	}
 	sp.preset = preset
+	sp.redraw = keys.Redraw(sp, &sp.active, sp.draw)
 
 	return sp
 }
@@ -93,6 +158,11 @@ func (sp *rotatorPage) Set(btnIndex int, state esd.BtnState) esd.Page {
 	sp.Lock()
 	defer sp.Unlock()
 
+	if btnIndex == newPosBtn {
+		sp.edit(state)
+		return nil
+	}
+
 	if state == esd.BtnReleased {
 		return nil
 	}
@@ -100,40 +170,146 @@ func (sp *rotatorPage) Set(btnIndex int, state esd.BtnState) esd.Page {
 	switch btnIndex {
 	case 4:
 		return sp.parent()
-	case 5:
-		dir, err := strconv.Atoi(sp.newPosText)
-		if err != nil {
-			log.Println(err)
-			break
+	case setBtn:
+		if len(sp.newPosText) == 0 {
+			sp.stopRotator()
+			return nil
 		}
-		sp.rotator.SetAzimuth(dir)
-		return sp.parent()
+		return sp.setAzimuth()
+	case lpBtn:
+		sp.lp.SetState(!sp.lp.State())
+		sp.lp.Draw()
+		return nil
 	case 9:
-		return presetpage.NewPresetPage(sp.sd, sp, sp.rotator)
+		return presetpage.NewPresetPage(sp.sd, sp, sp.rotator, sp.hub, sp.il, sp.config.presets(), sp.config.Locator, sp.newJogPage, sp.config.Store)
 	}
 
-	_, ok := sp.numPad[btnIndex]
+	num, ok := sp.keyPadMapping[btnIndex]
 	if ok {
-		if len(sp.newPosText) > 3 {
+		if len(sp.newPosText) >= maxDigits {
 			return nil
 		}
-		num := sp.keyPadMapping[btnIndex]
-		sp.newPosText = sp.newPosText + strconv.Itoa(num)
-		sp.newPos.SetText(sp.newPosText)
-		sp.draw()
+		sp.setText(sp.newPosText + strconv.Itoa(num))
 	}
 
 	return nil
 }
 
+// newJogPage returns the jog page of the rotator, which is opened from
+// the preset page.
+func (sp *rotatorPage) newJogPage(parent esd.Page) esd.Page {
+	return newJogPage(sp.sd, parent, sp.rotator, sp.hub, sp.il, sp.config.Jog)
+}
+
+// stopRotator stops the rotator, e.g. after it has been turned to a
+// wrong heading. The caller must hold the lock.
+func (sp *rotatorPage) stopRotator() {
+	if err := sp.rotator.Stop(); err != nil {
+		log.Println(err)
+		keys.Flash(sp.sd, setBtn, sp.redraw)
+	}
+}
+
+// setAzimuth turns the rotator to the entered heading, or, if the LP
+// modifier is on, to the reciprocal heading (long path). The caller must
+// hold the lock.
+func (sp *rotatorPage) setAzimuth() esd.Page {
+	longPath := sp.lp.State()
+
+	if err := sp.il.Check(); err != nil {
+		log.Println(err)
+		keys.Flash(sp.sd, setBtn, sp.redraw)
+		return nil
+	}
+
+	az, err := sp.azimuth(longPath)
+	if err != nil {
+		log.Println(err)
+		sp.showError()
+		return nil
+	}
+
+	// recorded first, so that the heading updates caused by
+	// SetAzimuth already carry the long path mark
+	sp.hub.SetLongPath(sp.rotator.Name(), az, longPath)
+	if err := sp.rotator.SetAzimuth(az); err != nil {
+		log.Println(err)
+		sp.hub.SetLongPath(sp.rotator.Name(), az, false)
+		sp.showError()
+		return nil
+	}
+
+	return sp.parent()
+}
+
+// edit handles the display key. A short press deletes the last digit,
+// holding the key clears the entered heading.
+func (sp *rotatorPage) edit(state esd.BtnState) {
+	sp.newPos.Edit(state,
+		func() {
+			if len(sp.newPosText) > 0 {
+				sp.setText(sp.newPosText[:len(sp.newPosText)-1])
+			}
+		},
+		func() {
+			sp.setText("")
+		})
+}
+
+// setText sets the entered heading and updates the display key and
+// the SET / STOP key. The caller must hold the lock.
+func (sp *rotatorPage) setText(text string) {
+	sp.newPosText = text
+	sp.newPos.SetText(text)
+	if sp.active {
+		sp.drawSet()
+	}
+}
+
+// drawSet draws SET, or STOP while no heading has been entered. The
+// caller must hold the lock.
+func (sp *rotatorPage) drawSet() {
+	if len(sp.newPosText) == 0 {
+		sp.stop.Draw()
+		return
+	}
+	sp.set.Draw()
+}
+
+// azimuth returns the entered heading, or its reciprocal for the long
+// path, if it is within the range of the rotator. The caller must hold
+// the lock.
+func (sp *rotatorPage) azimuth(longPath bool) (int, error) {
+	az, err := strconv.Atoi(sp.newPosText)
+	if err != nil {
+		return 0, err
+	}
+	if longPath {
+		az = heading.Reciprocal(az)
+	}
+	if err := azimuth.Check(sp.rotator.Serialize().Config, az); err != nil {
+		return 0, fmt.Errorf("%s: %v", sp.rotator.Name(), err)
+	}
+	return az, nil
+}
+
+// showError shows an error on the display key for a moment and
+// clears the entered heading. The caller must hold the lock.
+func (sp *rotatorPage) showError() {
+	sp.setText("")
+	sp.newPos.Stop()
+	sp.newPos.ShowError()
+}
+
 func (sp *rotatorPage) draw() {
 	for _, btn := range sp.numPad {
 		btn.Draw()
 	}
 	sp.newPos.Draw()
 	sp.preset.Draw()
+	sp.lp.Draw()
 	sp.back.Draw()
-	sp.set.Draw()
+	sp.drawSet()
 }
 
 func (sp *rotatorPage) Draw() {
@@ -152,8 +328,15 @@ func (sp *rotatorPage) Parent() esd.Page {
 	return sp.parent()
 }
 
+// SetActive sets the page active. When the page is left, the entered
+// heading is discarded.
 func (sp *rotatorPage) SetActive(active bool) {
 	sp.Lock()
 	defer sp.Unlock()
 	sp.active = active
+	if active {
+		return
+	}
+	sp.newPos.Stop()
+	sp.setText("")
 }
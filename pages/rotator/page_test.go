@@ -0,0 +1,114 @@
+package rotatorpage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	esd "github.com/dh1tw/streamdeck"
+	"github.com/dh1tw/touchctl/hub"
+	"github.com/dh1tw/touchctl/internal/rotatortest"
+	presetpage "github.com/dh1tw/touchctl/pages/preset"
+	"github.com/dh1tw/touchctl/tx"
+)
+
+func TestConfigPresets(t *testing.T) {
+
+	n := presetpage.Preset{Name: "N", Azimuth: 0}
+	e := presetpage.Preset{Name: "E", Azimuth: 90}
+	ja := presetpage.Preset{Name: "JA", Azimuth: 38}
+
+	tests := []struct {
+		name   string
+		config Config
+		want   []presetpage.Preset
+	}{
+		{"none", Config{}, []presetpage.Preset{}},
+		{"presets", Config{Presets: []presetpage.Preset{n, e}}, []presetpage.Preset{n, e}},
+		{"prefixes", Config{Prefixes: []presetpage.Preset{ja}}, []presetpage.Preset{ja}},
+		{"presets and prefixes", Config{Presets: []presetpage.Preset{n, e}, Prefixes: []presetpage.Preset{ja}}, []presetpage.Preset{n, e, ja}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.config.presets(); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	// the presets of the configuration must not be modified
+	c := Config{Presets: make([]presetpage.Preset, 1, 4), Prefixes: []presetpage.Preset{ja}}
+	c.presets()
+	if c.Presets[:2][1] != (presetpage.Preset{}) {
+		t.Fatalf("presets modified: %v", c.Presets[:2])
+	}
+}
+
+// newTestPage returns a rotator page which is never shown, so nothing
+// is drawn.
+func newTestPage(t *testing.T) (*rotatorPage, *rotatortest.Fake) {
+	h, err := hub.NewHub()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rotatortest.New("Yagi", rotator.Config{HasAzimuth: true, AzimuthMin: 0, AzimuthMax: 359})
+	sp := NewRotatorPage(&esd.StreamDeck{}, nil, r, h, tx.NewInterlock(), Config{}).(*rotatorPage)
+	return sp, r
+}
+
+func TestStopKey(t *testing.T) {
+
+	sp, r := newTestPage(t)
+
+	// no heading entered: the SET key stops the rotator
+	sp.Set(setBtn, esd.BtnPressed)
+	if r.Stops() != 1 || len(r.Sets()) != 0 {
+		t.Fatalf("got %d stops and azimuths %v, want 1 stop", r.Stops(), r.Sets())
+	}
+
+	// with a heading, the rotator is turned
+	sp.Set(2, esd.BtnPressed)
+	sp.Set(10, esd.BtnPressed)
+	sp.Set(setBtn, esd.BtnPressed)
+	if r.Stops() != 1 || !reflect.DeepEqual(r.Sets(), []int{20}) {
+		t.Fatalf("got %d stops and azimuths %v, want 1 stop and [20]", r.Stops(), r.Sets())
+	}
+}
+
+func TestEntry(t *testing.T) {
+
+	// keys 3, 2, 1 enter 1, 2, 3; key 10 enters 0
+	const backspace = newPosBtn
+
+	tests := []struct {
+		name string
+		keys []int
+		want string
+	}{
+		{"digits", []int{3, 2}, "12"},
+		{"max digits", []int{3, 2, 1, 10, 3}, "123"},
+		{"backspace", []int{3, 2, backspace}, "1"},
+		{"backspace on empty entry", []int{backspace, 3}, "1"},
+		{"backspace after max digits", []int{3, 2, 1, 10, backspace, 10}, "120"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sp, _ := newTestPage(t)
+			for _, k := range tc.keys {
+				sp.Set(k, esd.BtnPressed)
+				sp.Set(k, esd.BtnReleased)
+			}
+			if sp.newPosText != tc.want {
+				t.Fatalf("got %q, want %q", sp.newPosText, tc.want)
+			}
+
+			// the entry is discarded when the page is left
+			sp.SetActive(false)
+			if sp.newPosText != "" {
+				t.Fatalf("got %q after leaving the page, want an empty entry", sp.newPosText)
+			}
+		})
+	}
+}
@@ -1,10 +1,10 @@
 package stackpage
 
 import (
+	"errors"
 	"fmt"
 	"image/color"
 	"log"
-	"sort"
 	"sync"
 
 	Switch "github.com/dh1tw/remoteSwitch/switch"
@@ -14,7 +14,12 @@ import (
 	"github.com/dh1tw/streamdeck-buttons/label"
 	ledBtn "github.com/dh1tw/streamdeck-buttons/ledbutton"
 	"github.com/dh1tw/touchctl/hub"
+	"github.com/dh1tw/touchctl/pages/heading"
+	"github.com/dh1tw/touchctl/pages/keys"
+	"github.com/dh1tw/touchctl/pages/offline"
+	presetpage "github.com/dh1tw/touchctl/pages/preset"
 	rotatorpage "github.com/dh1tw/touchctl/pages/rotator"
+	"github.com/dh1tw/touchctl/tx"
 )
 
 type StackPage struct {
@@ -22,168 +27,185 @@ type StackPage struct {
 	sync.Mutex
 	ownParent esd.Page
 	stack     *stackmatch
+	terminals map[int]string // key: button index, value: terminal name
 	rotators  map[int]*rot
 	labels    map[int]*label.Label
+	offline   map[int]*label.Label // key: button index; shown while the stackmatch is unavailable
+	txLabel   *label.Label
 	hub       *hub.Hub
+	il        *tx.Interlock
 	active    bool
 	config    StackConfig
 }
 
 type rot struct {
-	rotator rotator.Rotator
-	label   *label.Label
+	name    string
+	btn     int
+	rotator rotator.Rotator // nil while the rotator is unavailable
+	heading *heading.Widget
+	offline *label.Label        // shown while the rotator is unavailable
+	presets []presetpage.Preset // empty: station presets
 }
 
-type stackmatch struct {
-	sync.Mutex
-	sm   Switch.Switcher
-	btns map[string]*ledBtn.LedButton
-}
-
-func (sm *stackmatch) set(terminalName string) error {
-	sm.Lock()
-	defer sm.Unlock()
-	t, ok := sm.btns[terminalName]
-	if !ok {
-		return fmt.Errorf("unknown terminal %s", terminalName)
-	}
-
-	p := Switch.Port{
-		Name: "SM",
-		Terminals: []Switch.Terminal{
-			Switch.Terminal{
-				Name:  terminalName,
-				State: !t.State(),
-			},
-		},
-	}
-
-	err := sm.sm.SetPort(p)
-	if err != nil {
-		return err
-	}
-
-	return nil
+// SmRotator is a rotator shown on the stack page. The heading is shown
+// on Button, the short name on LabelButton.
+type SmRotator struct {
+	Name        string
+	ShortName   string // max 5 char
+	Button      int
+	LabelButton int
+	Compass     bool                // show the heading as compass rose
+	Presets     []presetpage.Preset // replace the presets of the rotator pages
 }
 
 type SmTerminal struct {
 	Name      string // Full name
 	ShortName string // max 4 char
 	Index     int
+	Button    int // button index on the stack page
+}
+
+// SmCombination is a named pattern of terminals (e.g. "BIP", "ALL") which
+// is set with a single button press. All terminals of the pattern
+// will be switched on, all other terminals off.
+type SmCombination struct {
+	Name      string // max 5 char
+	Button    int
+	Terminals []string
 }
 
 type StackConfig struct {
-	Band string
-	Name string
-	Ant1 SmTerminal
-	Ant2 SmTerminal
-	Ant3 SmTerminal
-	Ant4 SmTerminal
+	Band         string
+	Name         string
+	Rotators     []SmRotator
+	Terminals    []SmTerminal
+	Combinations []SmCombination
+	MinActive    int // minimum number of active terminals (0: no limit)
+	MaxActive    int // maximum number of active terminals (0: no limit)
+	Rotator      rotatorpage.Config
 }
 
-func NewStackPage(sd *esd.StreamDeck, parent esd.Page, h *hub.Hub, smConfig StackConfig) *StackPage {
+// NewStackPage returns the page of a band's stackmatch. The stackmatch switch
+// and the rotators don't have to be registered in the hub yet; until they
+// show up, their buttons are shown as offline (see Refresh). While the
+// interlock il is locked, the stackmatch can not be switched.
+func NewStackPage(sd *esd.StreamDeck, parent esd.Page, h *hub.Hub, il *tx.Interlock, smConfig StackConfig) (*StackPage, error) {
 
 	sp := &StackPage{
 		sd:        sd,
 		ownParent: parent,
+		terminals: make(map[int]string),
 		rotators:  make(map[int]*rot, 0),
 		labels:    make(map[int]*label.Label),
+		offline:   make(map[int]*label.Label),
 		hub:       h,
+		il:        il,
 		config:    smConfig,
 		active:    false,
 	}
 
-	labels := map[int]string{
-		3: "TWR1",
-		2: "TWR2",
-		1: "TWR3",
-		0: "TWR4",
-	}
-
-	for pos, txt := range labels {
-		tb, err := label.NewLabel(sd, pos, label.Text(txt), label.TextColor(color.RGBA{92, 184, 92, 255}))
+	for _, r := range smConfig.Rotators {
+		tb, err := label.NewLabel(sd, r.LabelButton, label.Text(r.ShortName), label.TextColor(color.RGBA{92, 184, 92, 255}))
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-		sp.labels[pos] = tb
+		sp.labels[r.LabelButton] = tb
 	}
 
 	bandLabel, err := label.NewLabel(sd, 14, label.Text(smConfig.Band), label.TextColor(color.RGBA{255, 0, 0, 255}))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	sp.labels[14] = bandLabel
 
-	rots := sp.hub.Rotators()
-	sort.Slice(rots, func(i, j int) bool {
-		return rots[i].Name() < rots[j].Name()
-	})
-
-	stack, exists := sp.hub.Switch(smConfig.Name)
-	if !exists {
-		log.Fatalf("%v doesn't exist", smConfig.Name)
-	}
-
-	port, err := stack.GetPort("SM")
+	txLabel, err := label.NewLabel(sd, 14, label.Text("TX"),
+		label.BgColor(color.RGBA{255, 0, 0, 255}),
+		label.TextColor(color.RGBA{255, 255, 255, 255}))
 	if err != nil {
-		log.Fatalf("port SM on %v doesn't exist", smConfig.Name)
+		return nil, err
 	}
+	sp.txLabel = txLabel
 
 	sm := &stackmatch{
-		sm:   stack,
-		btns: make(map[string]*ledBtn.LedButton),
+		name:      smConfig.Name,
+		btns:      make(map[string]*ledBtn.LedButton),
+		combos:    make(map[int]*combination),
+		minActive: smConfig.MinActive,
+		maxActive: smConfig.MaxActive,
 	}
 
-	for _, t := range port.Terminals {
+	for _, t := range smConfig.Terminals {
+		b, err := ledBtn.NewLedButton(sd, t.Button, ledBtn.Text(t.ShortName))
+		if err != nil {
+			return nil, err
+		}
+		sm.btns[t.Name] = b
+		sp.terminals[t.Button] = t.Name
+		if err := sp.addOfflineLabel(t.Button); err != nil {
+			return nil, err
+		}
+	}
 
-		switch t.Name {
-		case smConfig.Ant1.Name:
-			b, err := ledBtn.NewLedButton(sd, 13, ledBtn.Text(smConfig.Ant1.ShortName), ledBtn.State(t.State))
-			if err != nil {
-				log.Fatal(err)
-			}
-			sm.btns[smConfig.Ant1.Name] = b
-		case smConfig.Ant2.Name:
-			b, err := ledBtn.NewLedButton(sd, 12, ledBtn.Text(smConfig.Ant2.ShortName), ledBtn.State(t.State))
-			if err != nil {
-				log.Fatal(err)
-			}
-			sm.btns[smConfig.Ant2.Name] = b
-		case smConfig.Ant3.Name:
-			b, err := ledBtn.NewLedButton(sd, 11, ledBtn.Text(smConfig.Ant3.ShortName), ledBtn.State(t.State))
-			if err != nil {
-				log.Fatal(err)
-			}
-			sm.btns[smConfig.Ant3.Name] = b
-		case smConfig.Ant4.Name:
-			b, err := ledBtn.NewLedButton(sd, 10, ledBtn.Text(smConfig.Ant4.ShortName), ledBtn.State(t.State))
-			if err != nil {
-				log.Fatal(err)
-			}
-			sm.btns[smConfig.Ant4.Name] = b
+	for _, c := range smConfig.Combinations {
+		b, err := ledBtn.NewLedButton(sd, c.Button, ledBtn.Text(c.Name), ledBtn.LedColor(ledBtn.LEDYellow))
+		if err != nil {
+			return nil, err
+		}
+		combo := &combination{
+			name:      c.Name,
+			terminals: make(map[string]bool),
+			btn:       b,
+		}
+		for _, t := range c.Terminals {
+			combo.terminals[t] = true
+		}
+		sm.combos[c.Button] = combo
+		if err := sp.addOfflineLabel(c.Button); err != nil {
+			return nil, err
 		}
 	}
 
 	sp.stack = sm
 
-	counter := 8
-	for _, r := range rots {
-		fmt.Printf("%v - %v: %03d°\n", sp.config.Band, r.Name(), r.Azimuth())
-		lbl, err := label.NewLabel(sd, counter, label.Text(fmt.Sprintf("%03d°", r.Azimuth())))
+	for _, r := range smConfig.Rotators {
+		ol, err := offline.NewLabel(sd, r.Button)
 		if err != nil {
-			log.Panic(err)
+			return nil, err
 		}
-		r := &rot{
-			rotator: r,
-			label:   lbl,
+		var opts []func(*heading.Widget)
+		if r.Compass {
+			opts = append(opts, heading.Renderer(heading.RenderCompass))
 		}
+		sp.rotators[r.Button] = &rot{
+			name:    r.Name,
+			btn:     r.Button,
+			heading: heading.NewWidget(rotator.Heading{}, opts...),
+			offline: ol,
+			presets: r.Presets,
+		}
+	}
+
+	sp.refresh()
+
+	return sp, nil
+}
 
-		sp.rotators[counter] = r
-		counter--
+// addOfflineLabel adds the label which is shown on btnIndex while the
+// stackmatch is unavailable.
+func (sp *StackPage) addOfflineLabel(btnIndex int) error {
+	l, err := offline.NewLabel(sp.sd, btnIndex)
+	if err != nil {
+		return err
 	}
+	sp.offline[btnIndex] = l
+	return nil
+}
 
-	return sp
+func (r *rot) draw(sd *esd.StreamDeck) {
+	if err := r.heading.Draw(sd, r.btn); err != nil {
+		log.Println(err)
+	}
 }
 
 func (sp *StackPage) Set(btnIndex int, state esd.BtnState) esd.Page {
@@ -201,50 +223,192 @@ func (sp *StackPage) Set(btnIndex int, state esd.BtnState) esd.Page {
 			return nil
 		}
 		return sp.ownParent
-	case 10:
-		if err := sp.stack.set(sp.config.Ant4.Name); err != nil {
-			log.Println(err)
-		}
-	case 11:
-		if err := sp.stack.set(sp.config.Ant3.Name); err != nil {
-			log.Println(err)
+	default:
+		_, isTerminal := sp.terminals[btnIndex]
+		_, isCombination := sp.stack.combos[btnIndex]
+		if isTerminal || isCombination {
+			if err := sp.il.Check(); err != nil {
+				sp.refused(btnIndex, err)
+				return nil
+			}
 		}
-	case 12:
-		if err := sp.stack.set(sp.config.Ant2.Name); err != nil {
-			log.Println(err)
+		if tName, ok := sp.terminals[btnIndex]; ok {
+			if err := sp.stack.set(tName); err != nil {
+				sp.refused(btnIndex, err)
+			}
+			return nil
 		}
-	case 13:
-		if err := sp.stack.set(sp.config.Ant1.Name); err != nil {
-			log.Println(err)
+		if _, ok := sp.stack.combos[btnIndex]; ok {
+			if err := sp.stack.setCombination(btnIndex); err != nil {
+				sp.refused(btnIndex, err)
+			}
+			return nil
 		}
-	default: // rotator
+		// rotator
 		rot, ok := sp.rotators[btnIndex]
-		if ok {
-			return rotatorpage.NewRotatorPage(sp.sd, sp, rot.rotator)
+		if ok && rot.rotator != nil {
+			cfg := sp.config.Rotator
+			if len(rot.presets) > 0 {
+				cfg.Presets = rot.presets
+			}
+			return rotatorpage.NewRotatorPage(sp.sd, sp, rot.rotator, sp.hub, sp.il, cfg)
 		}
 	}
 
 	return nil
 }
 
+// refused logs the error and, if the request was refused due to the
+// switching policy or the TX lock, flashes the button red.
+func (sp *StackPage) refused(btnIndex int, err error) {
+	log.Println(err)
+	if !errors.Is(err, hub.ErrRefused) && err != tx.ErrLocked && err != errOffline {
+		return
+	}
+
+	keys.Flash(sp.sd, btnIndex, func() {
+		sp.Lock()
+		defer sp.Unlock()
+		if !sp.active {
+			return
+		}
+		sp.drawBtn(btnIndex)
+	})
+}
+
+// drawBtn redraws a single terminal or combination button.
+func (sp *StackPage) drawBtn(btnIndex int) {
+	if !sp.stack.online() {
+		if l, ok := sp.offline[btnIndex]; ok {
+			l.Draw()
+		}
+		return
+	}
+	if tName, ok := sp.terminals[btnIndex]; ok {
+		sp.stack.btns[tName].Draw()
+	}
+	if c, ok := sp.stack.combos[btnIndex]; ok {
+		c.btn.Draw()
+	}
+}
+
+// EventHandler dispatches the events of the hub.
+func (sp *StackPage) EventHandler(ev hub.Event) {
+	switch ev.Name {
+	case hub.UpdateHeading:
+		sp.RotatorUpdateHandler(ev.Rotator, *ev.Heading)
+	case hub.UpdateSwitch:
+		sp.SwitchUpdateHandler(ev.Switch, *ev.Device)
+	case hub.AddRotator, hub.RemoveRotator, hub.AddSwitch, hub.RemoveSwitch, hub.UpdateStale:
+		sp.Refresh()
+	}
+}
+
+// Refresh looks up the stackmatch and the rotators in the hub. Devices
+// which have not been discovered yet are shown as offline, devices
+// which are stale or have disappeared are shown with their last known
+// state and a "?" marker until they return.
+func (sp *StackPage) Refresh() {
+	sp.Lock()
+	defer sp.Unlock()
+	sp.refresh()
+	if sp.active {
+		sp.draw()
+	}
+}
+
+func (sp *StackPage) refresh() {
+	for pos, r := range sp.rotators {
+		hr, ok := sp.hub.Rotator(r.name)
+		if ok && !sp.hub.Stale(r.name) {
+			r.rotator = hr
+			r.heading.Update(rotator.Heading{
+				Azimuth:  hr.Azimuth(),
+				AzPreset: hr.AzPreset(),
+			})
+			r.heading.SetLongPath(sp.hub.LongPath(r.name, hr.AzPreset()))
+			continue
+		}
+		last := r.rotator
+		if ok {
+			last = hr
+		}
+		if last == nil {
+			continue // never seen
+		}
+		l, err := offline.NewStaleLabel(sp.sd, pos, fmt.Sprintf("%03d", last.Azimuth()))
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		r.offline = l
+		r.rotator = nil
+	}
+
+	sp.stack.Lock()
+	defer sp.stack.Unlock()
+
+	s, ok := sp.hub.Switch(sp.config.Name)
+	if !ok || sp.hub.Stale(sp.config.Name) {
+		if ok || sp.stack.sm != nil {
+			sp.markStale()
+		}
+		sp.stack.sm = nil
+		return
+	}
+
+	port, err := s.GetPort("SM")
+	if err != nil {
+		log.Printf("port SM on %v doesn't exist\n", sp.config.Name)
+		return
+	}
+
+	states := make(map[string]bool)
+	for _, t := range port.Terminals {
+		states[t.Name] = t.State
+	}
+	for tName, btn := range sp.stack.btns {
+		state, ok := states[tName]
+		if !ok {
+			log.Printf("terminal %s doesn't exist on port SM of %v\n", tName, sp.config.Name)
+		}
+		btn.SetState(state)
+	}
+	sp.stack.updateCombinations(port.Terminals)
+	sp.stack.sm = s
+}
+
+// markStale replaces the placeholders of the terminals and combinations
+// with their names, marked as stale. The caller must hold the stack's lock.
+func (sp *StackPage) markStale() {
+	btns := make(map[int]string)
+	for _, t := range sp.config.Terminals {
+		btns[t.Button] = t.ShortName
+	}
+	for _, c := range sp.config.Combinations {
+		btns[c.Button] = c.Name
+	}
+	for pos, text := range btns {
+		l, err := offline.NewStaleLabel(sp.sd, pos, text)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		sp.offline[pos] = l
+	}
+}
+
 func (sp *StackPage) RotatorUpdateHandler(r rotator.Rotator, status rotator.Heading) {
-	var rLabel *rot
 	sp.Lock()
 	defer sp.Unlock()
-	switch r.Name() {
-	case "Tower1":
-		rLabel = sp.rotators[8]
-	case "Tower2":
-		rLabel = sp.rotators[7]
-	case "Tower3":
-		rLabel = sp.rotators[6]
-	case "Tower4":
-		rLabel = sp.rotators[5]
-	}
-	if rLabel != nil {
-		rLabel.label.SetText(fmt.Sprintf("%03d°", r.Azimuth()))
+	for _, rb := range sp.rotators {
+		if rb.name != r.Name() || rb.rotator == nil {
+			continue
+		}
+		rb.heading.Update(status)
+		rb.heading.SetLongPath(sp.hub.LongPath(rb.name, status.AzPreset))
 		if sp.active {
-			rLabel.label.Draw()
+			rb.draw(sp.sd)
 		}
 	}
 }
@@ -255,6 +419,9 @@ func (sp *StackPage) SwitchUpdateHandler(s Switch.Switcher, device Switch.Device
 	if device.Name != sp.config.Name {
 		return
 	}
+	if !sp.stack.online() {
+		return // not added yet
+	}
 	p, err := s.GetPort("SM")
 	if err != nil {
 		log.Println(err)
@@ -265,13 +432,29 @@ func (sp *StackPage) SwitchUpdateHandler(s Switch.Switcher, device Switch.Device
 	for _, t := range p.Terminals {
 		btn, ok := sp.stack.btns[t.Name]
 		if !ok {
-			log.Printf("unknown terminal %s", t.Name)
+			continue // terminal not shown on this page
 		}
 		btn.SetState(t.State)
 		if sp.active {
 			btn.Draw()
 		}
 	}
+	sp.stack.updateCombinations(p.Terminals)
+	if sp.active {
+		for _, c := range sp.stack.combos {
+			c.btn.Draw()
+		}
+	}
+}
+
+// CheckPort returns an error if the request p to set a port of the switch
+// s (e.g. through the HTTP API) would violate the switching policy of the
+// stackmatch. Requests for other switches are accepted.
+func (sp *StackPage) CheckPort(s Switch.Switcher, p Switch.Port) error {
+	if s.Name() != sp.config.Name {
+		return nil
+	}
+	return sp.stack.checkPort(s, p)
 }
 
 func (sp *StackPage) SetActive(active bool) {
@@ -285,13 +468,33 @@ func (sp *StackPage) draw() {
 		label.Draw()
 	}
 
+	// the TX lock indication replaces the band label
+	if sp.il.Locked() {
+		sp.txLabel.Draw()
+	}
+
 	for _, rot := range sp.rotators {
-		rot.label.Draw()
+		if rot.rotator == nil {
+			rot.offline.Draw()
+			continue
+		}
+		rot.draw(sp.sd)
+	}
+
+	if !sp.stack.online() {
+		for _, l := range sp.offline {
+			l.Draw()
+		}
+		return
 	}
 
 	for _, btn := range sp.stack.btns {
 		btn.Draw()
 	}
+
+	for _, c := range sp.stack.combos {
+		c.btn.Draw()
+	}
 }
 
 func (sp *StackPage) Draw() {
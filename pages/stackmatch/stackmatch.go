@@ -0,0 +1,207 @@
+package stackpage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	Switch "github.com/dh1tw/remoteSwitch/switch"
+	ledBtn "github.com/dh1tw/streamdeck-buttons/ledbutton"
+	"github.com/dh1tw/touchctl/hub"
+)
+
+type stackmatch struct {
+	sync.Mutex
+	name      string
+	sm        Switch.Switcher              // nil while the stackmatch is unavailable
+	btns      map[string]*ledBtn.LedButton // key: terminal name
+	combos    map[int]*combination         // key: button index
+	minActive int                          // 0: no lower limit
+	maxActive int                          // 0: no upper limit
+}
+
+// errOffline is returned if the stackmatch has not been discovered (yet)
+// or is stale.
+var errOffline = errors.New("stackmatch is offline")
+
+type combination struct {
+	name      string
+	terminals map[string]bool // terminals which are switched on
+	btn       *ledBtn.LedButton
+}
+
+func (sm *stackmatch) set(terminalName string) error {
+	sm.Lock()
+	defer sm.Unlock()
+	if sm.sm == nil {
+		return errOffline
+	}
+	if _, ok := sm.btns[terminalName]; !ok {
+		return fmt.Errorf("unknown terminal %s", terminalName)
+	}
+
+	port, err := sm.sm.GetPort("SM")
+	if err != nil {
+		return err
+	}
+
+	current := states(port.Terminals)
+	on, ok := current[terminalName]
+	if !ok {
+		return fmt.Errorf("unknown terminal %s", terminalName)
+	}
+
+	requested := states(port.Terminals)
+	requested[terminalName] = !on
+
+	if err := sm.checkPolicy(current, requested); err != nil {
+		return err
+	}
+
+	p := Switch.Port{
+		Name: "SM",
+		Terminals: []Switch.Terminal{
+			Switch.Terminal{
+				Name:  terminalName,
+				State: !on,
+			},
+		},
+	}
+
+	return sm.sm.SetPort(p)
+}
+
+// setCombination sets all terminals of the stackmatch according to the
+// combination assigned to btnIndex with a single SetPort call.
+func (sm *stackmatch) setCombination(btnIndex int) error {
+	sm.Lock()
+	defer sm.Unlock()
+	if sm.sm == nil {
+		return errOffline
+	}
+	c, ok := sm.combos[btnIndex]
+	if !ok {
+		return fmt.Errorf("no combination on button %d", btnIndex)
+	}
+
+	port, err := sm.sm.GetPort("SM")
+	if err != nil {
+		return err
+	}
+
+	p := Switch.Port{
+		Name: "SM",
+	}
+
+	requested := make(map[string]bool)
+	for _, t := range port.Terminals {
+		requested[t.Name] = c.terminals[t.Name]
+		p.Terminals = append(p.Terminals, Switch.Terminal{
+			Name:  t.Name,
+			State: c.terminals[t.Name],
+		})
+	}
+
+	if err := sm.checkPolicy(states(port.Terminals), requested); err != nil {
+		return err
+	}
+
+	return sm.sm.SetPort(p)
+}
+
+// checkPort verifies that the request p to set a port of the stackmatch
+// s complies with the switching policy. Terminals which are not part of
+// the request keep their live state.
+func (sm *stackmatch) checkPort(s Switch.Switcher, p Switch.Port) error {
+	port, err := s.GetPort(p.Name)
+	if err != nil {
+		return err
+	}
+
+	current := states(port.Terminals)
+	requested := states(port.Terminals)
+	for _, t := range p.Terminals {
+		if _, ok := current[t.Name]; !ok {
+			return fmt.Errorf("unknown terminal %s", t.Name)
+		}
+		requested[t.Name] = t.State
+	}
+
+	return sm.checkPolicy(current, requested)
+}
+
+// states returns the state of each terminal.
+func states(terminals []Switch.Terminal) map[string]bool {
+	s := make(map[string]bool)
+	for _, t := range terminals {
+		s[t.Name] = t.State
+	}
+	return s
+}
+
+// checkPolicy verifies that the requested terminal states comply with
+// the minimum / maximum number of active terminals. If the current
+// states already violate the policy (e.g. after a change from outside
+// touchctl), requests which reduce the violation are accepted. Violations
+// are reported with errors which wrap hub.ErrRefused.
+func (sm *stackmatch) checkPolicy(current, requested map[string]bool) error {
+	active := numActive(requested)
+	if sm.violation(active) == 0 || sm.violation(active) < sm.violation(numActive(current)) {
+		return nil
+	}
+
+	if active < sm.minActive {
+		return fmt.Errorf("%s: %w, at least %d terminal(s) must remain active", sm.name, hub.ErrRefused, sm.minActive)
+	}
+	if sm.maxActive > 0 && active > sm.maxActive {
+		return fmt.Errorf("%s: %w, at most %d terminal(s) may be active", sm.name, hub.ErrRefused, sm.maxActive)
+	}
+
+	return nil
+}
+
+// numActive returns the number of active terminals.
+func numActive(states map[string]bool) int {
+	active := 0
+	for _, on := range states {
+		if on {
+			active++
+		}
+	}
+	return active
+}
+
+// violation returns by how many terminals the number of active
+// terminals is outside the policy.
+func (sm *stackmatch) violation(active int) int {
+	switch {
+	case active < sm.minActive:
+		return sm.minActive - active
+	case sm.maxActive > 0 && active > sm.maxActive:
+		return active - sm.maxActive
+	}
+	return 0
+}
+
+// updateCombinations lights up the LED of those combinations which exactly
+// match the current state of the terminals. The caller must hold the lock.
+func (sm *stackmatch) updateCombinations(terminals []Switch.Terminal) {
+	for _, c := range sm.combos {
+		match := true
+		for _, t := range terminals {
+			if t.State != c.terminals[t.Name] {
+				match = false
+				break
+			}
+		}
+		c.btn.SetState(match)
+	}
+}
+
+// online returns true if the stackmatch is registered in the hub and
+// not stale.
+func (sm *stackmatch) online() bool {
+	sm.Lock()
+	defer sm.Unlock()
+	return sm.sm != nil
+}
@@ -0,0 +1,122 @@
+package stackpage
+
+import (
+	"errors"
+	"testing"
+
+	Switch "github.com/dh1tw/remoteSwitch/switch"
+	"github.com/dh1tw/touchctl/hub"
+	"github.com/dh1tw/touchctl/internal/switchtest"
+)
+
+func TestCheckPolicy(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		min, max  int
+		current   map[string]bool
+		requested map[string]bool
+		ok        bool
+	}{
+		{"switch on within limits", 1, 0,
+			map[string]bool{"a": true, "b": false},
+			map[string]bool{"a": true, "b": true}, true},
+		{"switch off last terminal", 1, 0,
+			map[string]bool{"a": true, "b": false},
+			map[string]bool{"a": false, "b": false}, false},
+		{"no lower limit", 0, 0,
+			map[string]bool{"a": true},
+			map[string]bool{"a": false}, true},
+		{"exceed maximum", 1, 2,
+			map[string]bool{"a": true, "b": true, "c": false},
+			map[string]bool{"a": true, "b": true, "c": true}, false},
+		{"reach maximum", 1, 2,
+			map[string]bool{"a": true, "b": false, "c": false},
+			map[string]bool{"a": true, "b": true, "c": false}, true},
+		{"below minimum, switch on", 2, 0,
+			map[string]bool{"a": false, "b": false, "c": false},
+			map[string]bool{"a": true, "b": false, "c": false}, true},
+		{"below minimum, keep violation", 2, 0,
+			map[string]bool{"a": true, "b": false, "c": false},
+			map[string]bool{"a": false, "b": true, "c": false}, false},
+		{"below minimum, switch off", 2, 0,
+			map[string]bool{"a": true, "b": false, "c": false},
+			map[string]bool{"a": false, "b": false, "c": false}, false},
+		{"above maximum, switch off", 1, 1,
+			map[string]bool{"a": true, "b": true, "c": true},
+			map[string]bool{"a": true, "b": true, "c": false}, true},
+		{"above maximum, switch on", 1, 2,
+			map[string]bool{"a": true, "b": true, "c": true, "d": false},
+			map[string]bool{"a": true, "b": true, "c": true, "d": true}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sm := &stackmatch{
+				name:      "sm",
+				minActive: tc.min,
+				maxActive: tc.max,
+			}
+			err := sm.checkPolicy(tc.current, tc.requested)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tc.ok {
+				if err == nil {
+					t.Fatal("expected the request to be refused")
+				}
+				if !errors.Is(err, hub.ErrRefused) {
+					t.Fatalf("expected a refusal, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckPort(t *testing.T) {
+
+	s := switchtest.New("sm", Switch.Port{
+		Name: "SM",
+		Terminals: []Switch.Terminal{
+			{Name: "a", State: true},
+			{Name: "b"},
+			{Name: "c"},
+		},
+	})
+	sm := &stackmatch{name: "sm", minActive: 1, maxActive: 2}
+
+	tests := []struct {
+		name      string
+		terminals []Switch.Terminal
+		ok        bool
+		refused   bool // refused by the policy, not failed
+	}{
+		{"switch on", []Switch.Terminal{{Name: "b", State: true}}, true, false},
+		{"switch off last terminal", []Switch.Terminal{{Name: "a"}}, false, true},
+		{"move", []Switch.Terminal{{Name: "a"}, {Name: "b", State: true}}, true, false},
+		{"exceed maximum", []Switch.Terminal{{Name: "b", State: true}, {Name: "c", State: true}}, false, true},
+		{"unknown terminal", []Switch.Terminal{{Name: "d", State: true}}, false, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := sm.checkPort(s, Switch.Port{Name: "SM", Terminals: tc.terminals})
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected the request to be refused")
+			}
+			if refused := errors.Is(err, hub.ErrRefused); refused != tc.refused {
+				t.Fatalf("got refused %v, want %v (%v)", refused, tc.refused, err)
+			}
+		})
+	}
+
+	// a switch which can't be reached is not a refusal
+	s.SetErr(errors.New("connection lost"))
+	err := sm.checkPort(s, Switch.Port{Name: "SM", Terminals: []Switch.Terminal{{Name: "b", State: true}}})
+	if err == nil || errors.Is(err, hub.ErrRefused) {
+		t.Fatalf("got %v, want the connection error", err)
+	}
+}
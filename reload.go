@@ -0,0 +1,48 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// configPollInterval is the interval in which the configuration file
+// is checked for modifications.
+const configPollInterval = time.Second * 2
+
+// watchConfig is a blocking function which executes reload whenever the
+// configuration file at path has been modified or a SIGHUP is received.
+func watchConfig(path string, reload func()) {
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	modTime := time.Time{}
+	if fi, err := os.Stat(path); err == nil {
+		modTime = fi.ModTime()
+	}
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-hup:
+			log.Println("SIGHUP received, reloading configuration")
+			reload()
+		case <-ticker.C:
+			fi, err := os.Stat(path)
+			if err != nil {
+				continue // the file might be in the middle of being replaced
+			}
+			if fi.ModTime().Equal(modTime) {
+				continue
+			}
+			modTime = fi.ModTime()
+			log.Printf("%s modified, reloading configuration", path)
+			reload()
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package rigctld
+
+import (
+	"log"
+	"time"
+)
+
+// Poller periodically queries a rigctld server and calls the event
+// handlers whenever the PTT state or the frequency has changed. If the
+// connection gets lost, the Poller will reconnect; a radio which was
+// transmitting is regarded as transmitting until it can be polled again.
+type Poller struct {
+	address     string
+	interval    time.Duration
+	timeout     time.Duration
+	pttHandler  func(tx bool)
+	freqHandler func(frequency int)
+	doneCh      chan struct{}
+}
+
+// NewPoller returns an initialized Poller. Functional options can be
+// supplied to modify its default behaviour.
+func NewPoller(opts ...func(*Poller)) *Poller {
+	p := &Poller{
+		address:  "localhost:4532",
+		interval: time.Millisecond * 200,
+		timeout:  time.Second * 2,
+		doneCh:   make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// Address is a functional option to set the address (host:port) of
+// the rigctld server.
+func Address(address string) func(*Poller) {
+	return func(p *Poller) {
+		p.address = address
+	}
+}
+
+// Interval is a functional option to set the poll interval.
+func Interval(d time.Duration) func(*Poller) {
+	return func(p *Poller) {
+		p.interval = d
+	}
+}
+
+// PTTHandler is a functional option which sets the handler which gets
+// called when the PTT state changes. If no PTTHandler is set, the PTT
+// state won't be polled.
+func PTTHandler(h func(tx bool)) func(*Poller) {
+	return func(p *Poller) {
+		p.pttHandler = h
+	}
+}
+
+// FrequencyHandler is a functional option which sets the handler which
+// gets called when the frequency changes. If no FrequencyHandler is set,
+// the frequency won't be polled.
+func FrequencyHandler(h func(frequency int)) func(*Poller) {
+	return func(p *Poller) {
+		p.freqHandler = h
+	}
+}
+
+// Close stops the Poller.
+func (p *Poller) Close() {
+	close(p.doneCh)
+}
+
+// Run is a blocking function which polls the rigctld server until
+// Close is called.
+func (p *Poller) Run() {
+
+	var c *Client
+	var err error
+
+	tx := false
+	freq := 0
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.doneCh:
+			if c != nil {
+				c.Close()
+			}
+			return
+		case <-ticker.C:
+		}
+
+		if c == nil {
+			c, err = Dial(p.address, p.timeout)
+			if err != nil {
+				c = nil
+				continue
+			}
+			log.Printf("connected to rigctld (%s)\n", p.address)
+		}
+
+		if err := p.poll(c, &tx, &freq); err != nil {
+			log.Printf("rigctld (%s): %v\n", p.address, err)
+			c.Close()
+			c = nil
+			// without connection we can't tell if the radio is still
+			// transmitting. The TX state is kept until a successful
+			// poll reports that the PTT has been released.
+		}
+	}
+}
+
+func (p *Poller) poll(c *Client, tx *bool, freq *int) error {
+	if p.pttHandler != nil {
+		ptt, err := c.PTT()
+		if err != nil {
+			return err
+		}
+		if ptt != *tx {
+			*tx = ptt
+			p.pttHandler(ptt)
+		}
+	}
+
+	if p.freqHandler != nil {
+		f, err := c.Frequency()
+		if err != nil {
+			return err
+		}
+		if f != *freq {
+			*freq = f
+			p.freqHandler(f)
+		}
+	}
+
+	return nil
+}
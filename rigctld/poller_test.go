@@ -0,0 +1,106 @@
+package rigctld
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dh1tw/touchctl/internal/rigctldtest"
+)
+
+func expectEvent(t *testing.T, ch chan int, want int) {
+	t.Helper()
+	select {
+	case got := <-ch:
+		if got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+	case <-time.After(time.Second * 2):
+		t.Fatalf("timeout waiting for %d", want)
+	}
+}
+
+func expectNoEvent(t *testing.T, ch chan int, d time.Duration) {
+	t.Helper()
+	select {
+	case got := <-ch:
+		t.Fatalf("unexpected event %d", got)
+	case <-time.After(d):
+	}
+}
+
+func TestPoller(t *testing.T) {
+
+	rig := rigctldtest.New(t)
+	rig.Set(0, 14074000)
+
+	pttCh := make(chan int, 10)
+	freqCh := make(chan int, 10)
+
+	p := NewPoller(
+		Address(rig.Address()),
+		Interval(time.Millisecond*10),
+		PTTHandler(func(tx bool) {
+			if tx {
+				pttCh <- 1
+			} else {
+				pttCh <- 0
+			}
+		}),
+		FrequencyHandler(func(frequency int) {
+			freqCh <- frequency
+		}),
+	)
+	go p.Run()
+	defer p.Close()
+
+	expectEvent(t, freqCh, 14074000)
+
+	rig.Set(0, 7074000)
+	expectEvent(t, freqCh, 7074000)
+
+	rig.Set(1, 7074000)
+	expectEvent(t, pttCh, 1)
+
+	// the connection drops while transmitting: the TX state must be kept
+	rig.SetDown(true)
+	expectNoEvent(t, pttCh, time.Millisecond*200)
+
+	// the radio is reachable again and has stopped transmitting
+	rig.Set(0, 7074000)
+	rig.SetDown(false)
+	expectEvent(t, pttCh, 0)
+	expectNoEvent(t, freqCh, time.Millisecond*50)
+}
+
+func TestPollerReconnectWhileTransmitting(t *testing.T) {
+
+	rig := rigctldtest.New(t)
+	rig.Set(1, 14074000)
+
+	pttCh := make(chan int, 10)
+
+	p := NewPoller(
+		Address(rig.Address()),
+		Interval(time.Millisecond*10),
+		PTTHandler(func(tx bool) {
+			if tx {
+				pttCh <- 1
+			} else {
+				pttCh <- 0
+			}
+		}),
+	)
+	go p.Run()
+	defer p.Close()
+
+	expectEvent(t, pttCh, 1)
+
+	// still transmitting after the reconnect: no event at all
+	rig.SetDown(true)
+	time.Sleep(time.Millisecond * 50)
+	rig.SetDown(false)
+	expectNoEvent(t, pttCh, time.Millisecond*200)
+
+	rig.Set(0, 14074000)
+	expectEvent(t, pttCh, 0)
+}
@@ -0,0 +1,101 @@
+// Package rigctld implements a minimal client for hamlib's rigctld
+// network daemon. It is used to poll the frequency and the PTT state
+// of a radio.
+package rigctld
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client is a connection to a rigctld server.
+type Client struct {
+	sync.Mutex
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+}
+
+// Dial connects to the rigctld server at address (host:port).
+func Dial(address string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		timeout: timeout,
+	}
+
+	return c, nil
+}
+
+// Close closes the connection to the rigctld server.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Frequency returns the current frequency of the radio in Hz.
+func (c *Client) Frequency() (int, error) {
+	res, err := c.cmd("f")
+	if err != nil {
+		return 0, err
+	}
+
+	// some rigs return the frequency with decimals
+	f, err := strconv.ParseFloat(res, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid frequency '%s'", res)
+	}
+
+	return int(f), nil
+}
+
+// PTT returns true if the radio is transmitting.
+func (c *Client) PTT() (bool, error) {
+	res, err := c.cmd("t")
+	if err != nil {
+		return false, err
+	}
+
+	ptt, err := strconv.Atoi(res)
+	if err != nil {
+		return false, fmt.Errorf("invalid ptt state '%s'", res)
+	}
+
+	// 0: RX, 1: TX, 2: TX mic, 3: TX data
+	return ptt > 0, nil
+}
+
+// cmd sends a command and returns the first line of the response.
+func (c *Client) cmd(cmd string) (string, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return "", err
+	}
+
+	if _, err := c.conn.Write([]byte(cmd + "\n")); err != nil {
+		return "", err
+	}
+
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSpace(line)
+
+	if strings.HasPrefix(line, "RPRT") {
+		return "", fmt.Errorf("rigctld command '%s' failed: %s", cmd, line)
+	}
+
+	return line, nil
+}
@@ -0,0 +1,134 @@
+// Package rigfollow maps the frequency of a radio to a band and notifies
+// whenever the radio has been tuned to another band.
+package rigfollow
+
+import (
+	"sync"
+)
+
+// Band is a frequency range in Hz.
+type Band struct {
+	Name string
+	Min  int
+	Max  int
+}
+
+// DefaultBands contains the IARU amateur radio bands. They are used
+// if no frequency range has been configured for a band.
+var DefaultBands = []Band{
+	{"160m", 1800000, 2000000},
+	{"80m", 3500000, 4000000},
+	{"60m", 5250000, 5450000},
+	{"40m", 7000000, 7300000},
+	{"30m", 10100000, 10150000},
+	{"20m", 14000000, 14350000},
+	{"17m", 18068000, 18168000},
+	{"15m", 21000000, 21450000},
+	{"12m", 24890000, 24990000},
+	{"10m", 28000000, 29700000},
+	{"6m", 50000000, 54000000},
+	{"4m", 70000000, 70500000},
+	{"2m", 144000000, 148000000},
+	{"70cm", 430000000, 440000000},
+}
+
+// DefaultBand returns the IARU band with the given name.
+func DefaultBand(name string) (Band, bool) {
+	for _, b := range DefaultBands {
+		if b.Name == name {
+			return b, true
+		}
+	}
+	return Band{}, false
+}
+
+// BandOf returns the name of the band which contains frequency (Hz).
+func BandOf(bands []Band, frequency int) (string, bool) {
+	for _, b := range bands {
+		if frequency >= b.Min && frequency <= b.Max {
+			return b.Name, true
+		}
+	}
+	return "", false
+}
+
+// Follower keeps track of the band a radio is tuned to. The event handler
+// is called whenever the band changes, as long as the Follower is enabled.
+type Follower struct {
+	sync.Mutex
+	bands        []Band
+	band         string
+	enabled      bool
+	eventHandler func(band string)
+}
+
+// NewFollower returns an initialized Follower.
+func NewFollower(bands []Band, enabled bool) *Follower {
+	return &Follower{
+		bands:   bands,
+		enabled: enabled,
+	}
+}
+
+// SetEventHandler sets the handler which gets called when the radio
+// has been tuned to another band.
+func (f *Follower) SetEventHandler(h func(band string)) {
+	f.Lock()
+	defer f.Unlock()
+	f.eventHandler = h
+}
+
+// SetBands replaces the band table.
+func (f *Follower) SetBands(bands []Band) {
+	f.Lock()
+	defer f.Unlock()
+	f.bands = bands
+}
+
+// SetFrequency updates the frequency (Hz) of the radio. Frequencies
+// outside of the known bands are ignored.
+func (f *Follower) SetFrequency(frequency int) {
+	f.Lock()
+	band, ok := BandOf(f.bands, frequency)
+	if !ok || band == f.band {
+		f.Unlock()
+		return
+	}
+	f.band = band
+	enabled := f.enabled
+	h := f.eventHandler
+	f.Unlock()
+
+	if enabled && h != nil {
+		h(band)
+	}
+}
+
+// Band returns the band the radio is currently tuned to.
+func (f *Follower) Band() string {
+	f.Lock()
+	defer f.Unlock()
+	return f.band
+}
+
+// Enabled returns true if the Follower is enabled.
+func (f *Follower) Enabled() bool {
+	f.Lock()
+	defer f.Unlock()
+	return f.enabled
+}
+
+// SetEnabled enables or disables the Follower. When the Follower gets
+// enabled, the event handler is called asynchronously with the current
+// band, so that the caller can catch up with the radio.
+func (f *Follower) SetEnabled(enabled bool) {
+	f.Lock()
+	defer f.Unlock()
+	if enabled == f.enabled {
+		return
+	}
+	f.enabled = enabled
+	if enabled && f.band != "" && f.eventHandler != nil {
+		go f.eventHandler(f.band)
+	}
+}
@@ -0,0 +1,114 @@
+package rigfollow_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dh1tw/touchctl/internal/rigctldtest"
+	"github.com/dh1tw/touchctl/rigctld"
+	"github.com/dh1tw/touchctl/rigfollow"
+)
+
+func expectBand(t *testing.T, ch chan string, want string) {
+	t.Helper()
+	select {
+	case got := <-ch:
+		if got != want {
+			t.Fatalf("got band %s, want %s", got, want)
+		}
+	case <-time.After(time.Second * 2):
+		t.Fatalf("timeout waiting for band %s", want)
+	}
+}
+
+func expectNoBand(t *testing.T, ch chan string) {
+	t.Helper()
+	select {
+	case got := <-ch:
+		t.Fatalf("unexpected band %s", got)
+	case <-time.After(time.Millisecond * 200):
+	}
+}
+
+func TestFollower(t *testing.T) {
+
+	rig := rigctldtest.New(t)
+	rig.Tune(14074000)
+
+	f := rigfollow.NewFollower(rigfollow.DefaultBands, true)
+	bands := make(chan string, 10)
+	f.SetEventHandler(func(band string) {
+		bands <- band
+	})
+
+	p := rigctld.NewPoller(
+		rigctld.Address(rig.Address()),
+		rigctld.Interval(time.Millisecond*10),
+		rigctld.FrequencyHandler(f.SetFrequency),
+	)
+	go p.Run()
+	defer p.Close()
+
+	expectBand(t, bands, "20m")
+
+	// tuning within the band
+	rig.Tune(14200000)
+	expectNoBand(t, bands)
+
+	rig.Tune(7074000)
+	expectBand(t, bands, "40m")
+
+	// out of band: the last band is kept
+	rig.Tune(8000000)
+	expectNoBand(t, bands)
+	if b := f.Band(); b != "40m" {
+		t.Fatalf("got band %s, want 40m", b)
+	}
+
+	// back into the same band
+	rig.Tune(7030000)
+	expectNoBand(t, bands)
+
+	// switched off: the band is tracked, but not reported
+	f.SetEnabled(false)
+	rig.Tune(21074000)
+	expectNoBand(t, bands)
+	if b := f.Band(); b != "15m" {
+		t.Fatalf("got band %s, want 15m", b)
+	}
+
+	// switched on: the current band is reported to catch up
+	f.SetEnabled(true)
+	expectBand(t, bands, "15m")
+	expectNoBand(t, bands)
+
+	rig.Tune(28074000)
+	expectBand(t, bands, "10m")
+}
+
+func TestBandOf(t *testing.T) {
+
+	bands := []rigfollow.Band{
+		{"40m", 7000000, 7200000},
+		{"20m", 14000000, 14350000},
+	}
+
+	tests := []struct {
+		freq int
+		band string
+		ok   bool
+	}{
+		{7000000, "40m", true},
+		{7200000, "40m", true},
+		{7200001, "", false},
+		{14074000, "20m", true},
+		{0, "", false},
+	}
+
+	for _, tc := range tests {
+		band, ok := rigfollow.BandOf(bands, tc.freq)
+		if band != tc.band || ok != tc.ok {
+			t.Errorf("BandOf(%d) = %s, %v; want %s, %v", tc.freq, band, ok, tc.band, tc.ok)
+		}
+	}
+}
@@ -16,8 +16,9 @@ import (
 
 type serviceCache struct {
 	sync.Mutex
-	ttl   time.Duration
-	cache map[string]time.Time
+	ttl   time.Duration        // devices not seen within ttl are stale
+	evict time.Duration        // devices not seen within evict are removed
+	cache map[string]time.Time // key: service name, value: last seen
 }
 
 type webserver struct {
@@ -85,6 +86,7 @@ func (w *webserver) watchRegistry() {
 			w.cache.Lock()
 			w.cache.cache[res.Service.Name] = time.Now()
 			w.cache.Unlock()
+			w.SetStale(nameFromFQSN(res.Service.Name), false)
 
 		case "delete":
 			serviceName := nameFromFQSN(res.Service.Name)
@@ -109,31 +111,61 @@ func (w *webserver) watchRegistry() {
 			w.cache.Unlock()
 		}
 
-		w.cache.Lock()
-		for service, timeout := range w.cache.cache {
-			if time.Since(timeout) >= w.cache.ttl {
-				serviceName := nameFromFQSN(service)
-				_isRotator := isRotator(serviceName)
-				_isSwitch := isSwitch(serviceName)
-
-				if _isRotator {
-					r, exists := w.Rotator(serviceName)
-					if !exists {
-						continue
-					}
-					r.Close()
-					delete(w.cache.cache, res.Service.Name)
-				} else if _isSwitch {
-					s, exists := w.Switch(serviceName)
-					if !exists {
-						continue
-					}
-					s.Close()
-					delete(w.cache.cache, res.Service.Name)
-				}
+		w.evictExpired()
+	}
+}
+
+// evictExpired closes the proxies of those rotators and switches whose
+// services have not been seen in the registry within the cache's evict
+// timeout. Closing a proxy removes the device from the hub.
+func (w *webserver) evictExpired() {
+	expired := []string{}
+
+	w.cache.Lock()
+	for service, lastSeen := range w.cache.cache {
+		if time.Since(lastSeen) >= w.cache.evict {
+			expired = append(expired, service)
+			delete(w.cache.cache, service)
+		}
+	}
+	w.cache.Unlock()
+
+	for _, service := range expired {
+		serviceName := nameFromFQSN(service)
+		if isRotator(service) {
+			if r, exists := w.Rotator(serviceName); exists {
+				r.Close()
+			}
+		} else if isSwitch(service) {
+			if s, exists := w.Switch(serviceName); exists {
+				s.Close()
 			}
 		}
+	}
+}
+
+// watchLiveness is a blocking function which periodically marks those
+// rotators and switches as stale whose services have not been seen in
+// the registry within the cache's ttl, and removes them once they
+// haven't been seen within the evict timeout.
+func (w *webserver) watchLiveness() {
+	ticker := time.NewTicker(w.cache.ttl / 4)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		stale := make(map[string]bool)
+		w.cache.Lock()
+		for service, lastSeen := range w.cache.cache {
+			stale[nameFromFQSN(service)] = time.Since(lastSeen) >= w.cache.ttl
+		}
 		w.cache.Unlock()
+
+		// SetStale notifies the subscribers; don't hold the cache meanwhile
+		for name, s := range stale {
+			w.SetStale(name, s)
+		}
+
+		w.evictExpired()
 	}
 }
 
@@ -158,7 +190,7 @@ func (w *webserver) addRotator(rotatorServiceName string) error {
 
 	done := sbRotatorProxy.DoneCh(doneCh)
 	cli := sbRotatorProxy.Client(w.cli)
-	eh := sbRotatorProxy.EventHandler(rotatorEvent)
+	eh := sbRotatorProxy.EventHandler(w.BroadcastHeading)
 	name := sbRotatorProxy.Name(rotatorName)
 	serviceName := sbRotatorProxy.ServiceName(strings.Replace(rotatorServiceName, " ", "_", -1))
 
@@ -197,7 +229,7 @@ func (w *webserver) addSwitch(switchServiceName string) error {
 
 	done := sbSwitchProxy.DoneCh(doneCh)
 	cli := sbSwitchProxy.Client(w.cli)
-	eh := sbSwitchProxy.EventHandler(switchEvent)
+	eh := sbSwitchProxy.EventHandler(w.BroadcastSwitch)
 	name := sbSwitchProxy.Name(switchName)
 	serviceName := sbSwitchProxy.ServiceName(strings.Replace(switchServiceName, " ", "_", -1))
 
@@ -252,6 +284,10 @@ func (w *webserver) listAndAddServices() error {
 			}
 
 		}
+
+		w.cache.Lock()
+		w.cache.cache[service.Name] = time.Now()
+		w.cache.Unlock()
 	}
 
 	return nil
@@ -0,0 +1,121 @@
+// Package store persists the presets which have been saved on the
+// Stream Deck in a local state file, so that they survive a restart.
+package store
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Preset is a heading which has been saved on the preset page.
+type Preset struct {
+	Name    string `json:"name"`
+	Azimuth int    `json:"azimuth"`
+}
+
+// state is the content of the state file.
+type state struct {
+	// key: rotator name, key: configured name of the preset
+	Presets map[string]map[string]Preset `json:"presets"`
+}
+
+// Store keeps the saved presets. If the Store has no path, the presets
+// are only kept in memory.
+type Store struct {
+	sync.RWMutex
+	path  string
+	state state
+}
+
+// Open loads the state file at path. If the file doesn't exist yet,
+// it is created with the first saved preset.
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path: path,
+		state: state{
+			Presets: make(map[string]map[string]Preset),
+		},
+	}
+
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.state); err != nil {
+		return nil, err
+	}
+	if s.state.Presets == nil {
+		s.state.Presets = make(map[string]map[string]Preset)
+	}
+
+	return s, nil
+}
+
+// Presets returns the saved presets of a rotator. The key is the name
+// under which the preset has been configured, so that a saved preset
+// stays with its preset if the configured presets are reordered.
+func (s *Store) Presets(rotator string) map[string]Preset {
+	s.RLock()
+	defer s.RUnlock()
+
+	presets := make(map[string]Preset)
+	for key, p := range s.state.Presets[rotator] {
+		presets[key] = p
+	}
+	return presets
+}
+
+// SavePreset stores the preset of a rotator under key, the configured
+// name of the preset, and writes the state file.
+func (s *Store) SavePreset(rotator, key string, p Preset) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.state.Presets[rotator]; !ok {
+		s.state.Presets[rotator] = make(map[string]Preset)
+	}
+	s.state.Presets[rotator][key] = p
+
+	return s.write()
+}
+
+// write replaces the state file. The caller must hold the lock.
+func (s *Store) write() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first, so that the state file doesn't
+	// get corrupted if we crash while writing
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "touchctl.state")
+
+	// the state file doesn't exist yet
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := s.Presets("Yagi"); len(p) != 0 {
+		t.Fatalf("got presets %v from a new store", p)
+	}
+
+	saves := []struct {
+		rotator string
+		key     string
+		preset  Preset
+	}{
+		{"Yagi", "NE", Preset{Name: "JA", Azimuth: 38}},
+		{"Yagi", "W#2", Preset{Name: "W6", Azimuth: 320}},
+		{"Dipole", "NE", Preset{Name: "NE", Azimuth: 50}},
+		{"Yagi", "NE", Preset{Name: "JA1", Azimuth: 36}}, // replaces the first save
+	}
+	for _, sv := range saves {
+		if err := s.SavePreset(sv.rotator, sv.key, sv.preset); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]map[string]Preset{
+		"Yagi": {
+			"NE":  {Name: "JA1", Azimuth: 36},
+			"W#2": {Name: "W6", Azimuth: 320},
+		},
+		"Dipole": {
+			"NE": {Name: "NE", Azimuth: 50},
+		},
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range []*Store{s, reopened} {
+		for rotator, presets := range want {
+			if got := st.Presets(rotator); !reflect.DeepEqual(got, presets) {
+				t.Fatalf("%s: got %v, want %v", rotator, got, presets)
+			}
+		}
+	}
+
+	// no temporary files are left behind
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("got %d files in the state directory, want 1", len(files))
+	}
+}
+
+func TestStoreWithoutPath(t *testing.T) {
+
+	s, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SavePreset("Yagi", "N", Preset{Name: "N", Azimuth: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if p := s.Presets("Yagi")["N"]; p.Azimuth != 5 {
+		t.Fatalf("got %v, want the preset kept in memory", p)
+	}
+}
+
+func TestStoreInvalidFile(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "touchctl.state")
+	if err := ioutil.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Fatal("invalid state file accepted")
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err != nil {
+		t.Fatalf("missing state file refused: %v", err)
+	}
+}
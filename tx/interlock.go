@@ -0,0 +1,108 @@
+// Package tx contains the transmit interlock which prevents that
+// antennas are switched while a radio is transmitting.
+package tx
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+// ErrLocked is returned when an action has been refused because a
+// radio is transmitting.
+var ErrLocked = errors.New("TX lock: radio is transmitting")
+
+// Interlock aggregates the transmit state of one or more sources
+// (e.g. radios). It is locked as long as at least one source reports
+// that it is transmitting. A nil *Interlock is never locked.
+type Interlock struct {
+	sync.RWMutex
+	sources      map[string]bool // key: source name, value: transmitting
+	eventHandler func(locked bool)
+}
+
+// NewInterlock returns an initialized Interlock.
+func NewInterlock() *Interlock {
+	return &Interlock{
+		sources: make(map[string]bool),
+	}
+}
+
+// SetEventHandler sets a handler which gets called whenever the
+// Interlock gets locked or unlocked.
+func (il *Interlock) SetEventHandler(h func(locked bool)) {
+	il.Lock()
+	defer il.Unlock()
+	il.eventHandler = h
+}
+
+// Set updates the transmit state of a source.
+func (il *Interlock) Set(source string, tx bool) {
+	il.Lock()
+	wasLocked := il.locked()
+	il.sources[source] = tx
+	locked := il.locked()
+	h := il.eventHandler
+	il.Unlock()
+
+	if locked == wasLocked {
+		return
+	}
+
+	if locked {
+		log.Printf("TX lock engaged (%s)\n", source)
+	} else {
+		log.Println("TX lock released")
+	}
+
+	if h != nil {
+		h(locked)
+	}
+}
+
+// Locked returns true if at least one source is transmitting.
+func (il *Interlock) Locked() bool {
+	if il == nil {
+		return false
+	}
+	il.RLock()
+	defer il.RUnlock()
+	return il.locked()
+}
+
+// Check returns ErrLocked if at least one source is transmitting.
+func (il *Interlock) Check() error {
+	if il.Locked() {
+		return ErrLocked
+	}
+	return nil
+}
+
+func (il *Interlock) locked() bool {
+	for _, tx := range il.sources {
+		if tx {
+			return true
+		}
+	}
+	return false
+}
+
+// SubscribeNats feeds the Interlock with the transmit state published
+// on a NATS subject. Payloads like "1", "true", "on" or "tx" are
+// interpreted as transmitting, everything else as receiving.
+func (il *Interlock) SubscribeNats(nc *nats.Conn, subject string) (*nats.Subscription, error) {
+	return nc.Subscribe(subject, func(msg *nats.Msg) {
+		il.Set("nats:"+subject, parseState(string(msg.Data)))
+	})
+}
+
+func parseState(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "true", "on", "tx":
+		return true
+	}
+	return false
+}